@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/huandu/xstrings"
+)
+
+// Config the settings of the generated code
+type Config struct {
+	Source  string // name of the sql file, written in the header of the files
+	Package string // import path of the output directory, example: github.com/acme/shop/internal
+}
+
+// Field a field of a model struct
+type Field struct {
+	Name    string
+	Type    string
+	Tag     string
+	Comment string
+}
+
+// Model the data of the templates of a table
+type Model struct {
+	Config
+	Table      *Table
+	Name       string // struct name
+	Embed      bool   // the table has the columns of database.Model
+	Fields     []*Field
+	Imports    []string
+	PKColumn   string // single primary key, empty when there is none or it is composite
+	PKType     string
+	Sort       string // default sort of the list handler when the table has no id column, which query.Page sorts by
+	ModelsPath string
+	ReposPath  string
+}
+
+// the columns of database.Model
+var modelColumns = []string{"id", "created_at", "updated_at", "deleted_at"}
+
+// go names of the common initialisms, example: user_id -> UserID
+var initialisms = map[string]bool{
+	"api": true, "ascii": true, "cpu": true, "css": true, "dns": true, "html": true, "http": true, "https": true,
+	"id": true, "ip": true, "json": true, "sku": true, "sql": true, "ssh": true, "tls": true, "ttl": true,
+	"ui": true, "uid": true, "uri": true, "url": true, "utf8": true, "uuid": true, "xml": true,
+}
+
+// Generate the model, repository and list handler of a table, the keys of the result are the paths of the files
+// relative to the output directory
+func Generate(table *Table, cfg Config) (map[string][]byte, error) {
+	m := newModel(table, cfg)
+	// TableName of the model is database.GetTableName, the snake case of the struct name
+	if name := xstrings.ToSnakeCase(m.Name); name != table.Name {
+		return nil, fmt.Errorf("table %s cannot be named by database.GetTableName, the struct %s maps to %s, rename the table", table.Name, m.Name, name)
+	}
+	fileName := strings.ToLower(table.Name) + ".go"
+
+	files := map[string][]byte{}
+	for dir, tpl := range map[string]*template.Template{"model": modelTemplate, "repository": repositoryTemplate, "handler": handlerTemplate} {
+		buf := &bytes.Buffer{}
+		if err := tpl.Execute(buf, m); err != nil {
+			return nil, fmt.Errorf("generate %s of table %s error, err: %w", dir, table.Name, err)
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("format %s of table %s error, err: %w", dir, table.Name, err)
+		}
+		files[path.Join(dir, fileName)] = src
+	}
+	return files, nil
+}
+
+func newModel(table *Table, cfg Config) *Model {
+	m := &Model{
+		Config:     cfg,
+		Table:      table,
+		Name:       goName(table.Name),
+		Embed:      embedsModel(table),
+		ModelsPath: path.Join(cfg.Package, "model"),
+		ReposPath:  path.Join(cfg.Package, "repository"),
+	}
+
+	imports := map[string]bool{}
+	for _, column := range table.Columns {
+		if m.Embed && isModelColumn(column.Name) {
+			continue
+		}
+		typ := goType(column)
+		if strings.Contains(typ, "time.") {
+			imports["time"] = true
+		}
+		m.Fields = append(m.Fields, &Field{
+			Name:    goName(column.Name),
+			Type:    typ,
+			Tag:     fmt.Sprintf("`gorm:\"%s\" json:\"%s\"`", gormTag(column), column.Name),
+			Comment: strings.Join(strings.Fields(column.Comment), " "),
+		})
+	}
+	if imports["time"] {
+		m.Imports = append(m.Imports, "time")
+	}
+
+	switch {
+	case m.Embed:
+		m.PKColumn, m.PKType = "id", "uint64"
+	case len(table.PrimaryKey) == 1:
+		if column := table.Column(table.PrimaryKey[0]); column != nil {
+			m.PKColumn, m.PKType = column.Name, goType(column)
+		}
+	}
+
+	if table.Column("id") == nil {
+		m.Sort = table.Columns[0].Name
+		if len(table.PrimaryKey) > 0 {
+			m.Sort = "-" + strings.Join(table.PrimaryKey, ",-")
+		}
+	}
+	return m
+}
+
+// the table has the columns of database.Model with compatible types and id is its only primary key
+func embedsModel(table *Table) bool {
+	for _, name := range modelColumns {
+		column := table.Column(name)
+		if column == nil {
+			return false
+		}
+		typ := goType(column)
+		if name == "id" && !strings.HasPrefix(typ, "int") && !strings.HasPrefix(typ, "uint") {
+			return false
+		}
+		if name != "id" && !strings.HasSuffix(typ, "time.Time") {
+			return false
+		}
+	}
+	return len(table.PrimaryKey) == 0 || (len(table.PrimaryKey) == 1 && strings.EqualFold(table.PrimaryKey[0], "id"))
+}
+
+func isModelColumn(name string) bool {
+	for _, column := range modelColumns {
+		if strings.EqualFold(column, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// goName the exported go name of a table or column, example: order_item -> OrderItem, user_id -> UserID
+func goName(name string) string {
+	s := ""
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			s += strings.ToUpper(word)
+			continue
+		}
+		s += xstrings.ToCamelCase(lower)
+	}
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "Col" + s
+	}
+	return s
+}
+
+// goType the go type of a column, nullable times are pointers
+func goType(column *ColumnDef) string {
+	typ := column.BaseType
+	unsigned := ""
+	if column.Unsigned {
+		unsigned = "u"
+	}
+
+	switch typ {
+	case "bool", "boolean":
+		return "bool"
+	case "tinyint":
+		if strings.HasPrefix(strings.ToLower(column.Type), "tinyint(1)") {
+			return "bool"
+		}
+		return unsigned + "int8"
+	case "smallint", "smallserial", "int2":
+		return unsigned + "int16"
+	case "mediumint", "int", "integer", "serial", "int4":
+		return unsigned + "int32"
+	case "bigint", "bigserial", "int8":
+		return unsigned + "int64"
+	case "float", "float4":
+		return "float32"
+	case "double", "real", "decimal", "numeric", "float8":
+		return "float64"
+	case "date", "datetime", "timestamp", "timestamptz":
+		if column.NotNull {
+			return "time.Time"
+		}
+		return "*time.Time"
+	case "binary", "varbinary", "blob", "tinyblob", "mediumblob", "longblob", "bytea":
+		return "[]byte"
+	}
+	return "string" // char, varchar, text, enum, json, time, uuid...
+}
+
+// gormTag the gorm tag of a column, example: column:name;type:varchar(40);not null
+func gormTag(column *ColumnDef) string {
+	parts := []string{"column:" + column.Name, "type:" + column.Type}
+	if column.PrimaryKey {
+		parts = append(parts, "primary_key")
+	}
+	if column.AutoIncrement {
+		parts = append(parts, "AUTO_INCREMENT")
+	}
+	if column.NotNull && !column.PrimaryKey {
+		parts = append(parts, "not null")
+	}
+	if value, ok := defaultValue(column.Default); ok {
+		parts = append(parts, "default:"+value)
+	}
+	return strings.Join(parts, ";")
+}
+
+// the literal defaults are kept so that gorm does not insert the zero value over them,
+// NULL and functions such as CURRENT_TIMESTAMP are left to the database
+func defaultValue(s string) (string, bool) {
+	if s == "" || strings.EqualFold(s, "null") || strings.ContainsAny(s, ";\"`()") {
+		return "", false
+	}
+	if s[0] == '\'' {
+		return s, len(s) > 2 && !strings.Contains(s[1:len(s)-1], "'")
+	}
+	if s[0] == '-' || s[0] == '.' || (s[0] >= '0' && s[0] <= '9') || strings.EqualFold(s, "true") || strings.EqualFold(s, "false") {
+		return s, true
+	}
+	return "", false
+}
+
+var modelTemplate = template.Must(template.New("model").Parse(`// Code generated by gencrud from {{.Source}}.
+
+package model
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/xingmoo/library/database"
+)
+
+// {{.Name}} {{if .Table.Comment}}{{.Table.Comment}}{{else}}object fields mapping table {{.Table.Name}}{{end}}
+type {{.Name}} struct {
+{{- if .Embed}}
+	database.Model ` + "`" + `gorm:"embedded"` + "`" + `
+{{end}}
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+
+// TableName get table name
+func (table *{{.Name}}) TableName() string {
+	return database.GetTableName(table)
+}
+`))
+
+var repositoryTemplate = template.Must(template.New("repository").Parse(`// Code generated by gencrud from {{.Source}}.
+
+package repository
+
+import (
+	"context"
+
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+
+	"{{.ModelsPath}}"
+)
+
+// {{.Name}}Repository the database operations of model.{{.Name}}
+type {{.Name}}Repository struct {
+	db *gorm.DB
+}
+
+// New{{.Name}}Repository create a repository of model.{{.Name}}
+func New{{.Name}}Repository(db *gorm.DB) *{{.Name}}Repository {
+	return &{{.Name}}Repository{db: db}
+}
+
+// Create a new record
+func (r *{{.Name}}Repository) Create(ctx context.Context, table *model.{{.Name}}) error {
+	return database.Create(ctx, r.db, table)
+}
+{{if .PKColumn}}
+// GetByID get record by {{.PKColumn}}
+func (r *{{.Name}}Repository) GetByID(ctx context.Context, id {{.PKType}}) (*model.{{.Name}}, error) {
+	table := &model.{{.Name}}{}
+	if err := database.Get(ctx, r.db, table, "{{.PKColumn}} = ?", id); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// UpdateByID update the columns of the record by {{.PKColumn}}
+func (r *{{.Name}}Repository) UpdateByID(ctx context.Context, id {{.PKType}}, update database.KV) error {
+	return database.Updates(ctx, r.db, &model.{{.Name}}{}, update, "{{.PKColumn}} = ?", id)
+}
+
+// DeleteByID delete record by {{.PKColumn}}
+func (r *{{.Name}}Repository) DeleteByID(ctx context.Context, id {{.PKType}}) error {
+	return database.Delete(ctx, r.db, &model.{{.Name}}{}, "{{.PKColumn}} = ?", id)
+}
+{{end}}
+// List the records matching the params, starting from page 0
+func (r *{{.Name}}Repository) List(ctx context.Context, params *query.Params) ([]*model.{{.Name}}, error) {
+	list := []*model.{{.Name}}{}
+	if err := database.ListByParams(ctx, r.db, &list, params); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+`))
+
+var handlerTemplate = template.Must(template.New("handler").Parse(`// Code generated by gencrud from {{.Source}}.
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/xingmoo/library/database/query"
+
+	"{{.ReposPath}}"
+)
+
+// {{.Name}}Handler the http handlers of model.{{.Name}}
+type {{.Name}}Handler struct {
+	repo *repository.{{.Name}}Repository
+}
+
+// New{{.Name}}Handler create the handlers of model.{{.Name}}
+func New{{.Name}}Handler(repo *repository.{{.Name}}Repository) *{{.Name}}Handler {
+	return &{{.Name}}Handler{repo: repo}
+}
+
+// List the records matching the query.Params of the json body, example:
+//
+//	{"page": 0, "size": 20, "columns": [{"name": "{{(index .Table.Columns 0).Name}}", "exp": "=", "value": "..."}]}
+func (h *{{.Name}}Handler) List(w http.ResponseWriter, r *http.Request) {
+	params := &query.Params{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.Size <= 0 {
+		params.Size = 20
+	}
+{{- if .Sort}}
+	if params.Sort == "" {
+		params.Sort = "{{.Sort}}"
+	}
+{{- end}}
+
+	list, err := h.repo.List(r.Context(), params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"list": list, "page": params.Page, "size": params.Size})
+}
+`))
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	tables, err := ParseDDL(testDDL)
+	require.NoError(t, err)
+	cfg := Config{Source: "schema.sql", Package: "github.com/acme/shop/internal"}
+
+	files, err := Generate(tables[0], cfg)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	for name, src := range files {
+		_, err = parser.ParseFile(token.NewFileSet(), name, src, parser.AllErrors)
+		assert.NoError(t, err, name)
+	}
+
+	model := string(files["model/user.go"])
+	assert.Contains(t, model, "// User users of the shop\n")
+	assert.Contains(t, model, "database.Model `gorm:\"embedded\"`")
+	assert.NotContains(t, model, "column:id;")
+	assert.Contains(t, model, "Name     string    `gorm:\"column:name;type:varchar(40);not null\" json:\"name\"` // user's name; unique")
+	assert.Contains(t, model, "Age      int32     `gorm:\"column:age;type:int(11);not null;default:18\" json:\"age\"`")
+	assert.Contains(t, model, "Score    float64   `gorm:\"column:score;type:decimal(10,2) unsigned\" json:\"score\"`")
+	assert.Contains(t, model, "default:'active'")
+	assert.Contains(t, model, "LoggedAt time.Time `gorm:\"column:logged_at;type:timestamp;not null\" json:\"logged_at\"`")
+	assert.Contains(t, model, "return database.GetTableName(table)")
+
+	repo := string(files["repository/user.go"])
+	assert.Contains(t, repo, `"github.com/acme/shop/internal/model"`)
+	assert.Contains(t, repo, "func (r *UserRepository) GetByID(ctx context.Context, id uint64) (*model.User, error)")
+	assert.Contains(t, repo, "database.ListByParams(ctx, r.db, &list, params)")
+
+	handler := string(files["handler/user.go"])
+	assert.Contains(t, handler, `"github.com/acme/shop/internal/repository"`)
+	assert.Contains(t, handler, "func (h *UserHandler) List(w http.ResponseWriter, r *http.Request)")
+	assert.NotContains(t, handler, "params.Sort =")
+
+	files, err = Generate(tables[1], cfg)
+	require.NoError(t, err)
+	model = string(files["model/order_items.go"])
+	assert.NotContains(t, model, "database.Model")
+	assert.Contains(t, model, "OrderID int64      `gorm:\"column:order_id;type:bigint;primary_key\" json:\"order_id\"`")
+	assert.Contains(t, model, "PaidAt  *time.Time")
+	assert.NotContains(t, string(files["repository/order_items.go"]), "GetByID")
+	assert.Contains(t, string(files["handler/order_items.go"]), `params.Sort = "-order_id,-sku"`)
+}
+
+func TestGenerate_tableName(t *testing.T) {
+	tables, err := ParseDDL("CREATE TABLE `UserLog` (`id` bigint NOT NULL, PRIMARY KEY (`id`));")
+	require.NoError(t, err)
+	_, err = Generate(tables[0], Config{Source: "schema.sql", Package: "github.com/acme/shop/internal"})
+	assert.EqualError(t, err, "table UserLog cannot be named by database.GetTableName, the struct Userlog maps to userlog, rename the table")
+}
+
+func TestRun_missingTables(t *testing.T) {
+	dir := t.TempDir()
+	sqlFile := filepath.Join(dir, "schema.sql")
+	require.NoError(t, os.WriteFile(sqlFile, []byte(testDDL), 0o644))
+
+	err := run(sqlFile, dir, "github.com/acme/shop/internal", "user,payment,coupon", false)
+	assert.EqualError(t, err, "tables coupon, payment not found in "+sqlFile)
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "OrderItem", goName("order_item"))
+	assert.Equal(t, "UserID", goName("user_id"))
+	assert.Equal(t, "AvatarURL", goName("avatar_url"))
+	assert.Equal(t, "Col2fa", goName("2fa"))
+}
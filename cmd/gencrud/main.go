@@ -0,0 +1,103 @@
+// Command gencrud generate the gorm models, repositories and list handlers of the tables of a sql file,
+// no database connection is needed, example:
+//
+//	go run github.com/xingmoo/library/cmd/gencrud -sql schema.sql -out ./internal -pkg github.com/acme/shop/internal
+//
+// the files are written to the model, repository and handler directories of -out, one file per table.
+// a model embeds database.Model when the table has its id, created_at, updated_at and deleted_at columns,
+// its TableName is database.GetTableName, so the table names must be in snake case.
+// existing files are kept unless -force is set
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	sqlFile := flag.String("sql", "", "sql file with the CREATE TABLE statements")
+	out := flag.String("out", ".", "output directory")
+	pkg := flag.String("pkg", "", "import path of the output directory, example: github.com/acme/shop/internal")
+	tables := flag.String("tables", "", "comma separated tables to generate, default is all the tables")
+	force := flag.Bool("force", false, "overwrite the existing files")
+	flag.Parse()
+
+	if *sqlFile == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*sqlFile, *out, *pkg, *tables, *force); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(sqlFile string, out string, pkg string, tables string, force bool) error {
+	ddl, err := os.ReadFile(sqlFile)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseDDL(string(ddl))
+	if err != nil {
+		return fmt.Errorf("parse %s error, err: %w", sqlFile, err)
+	}
+
+	selected := map[string]bool{}
+	for _, name := range strings.Split(tables, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	cfg := Config{Source: filepath.Base(sqlFile), Package: pkg}
+	count := 0
+	for _, table := range parsed {
+		if len(selected) > 0 && !selected[table.Name] {
+			continue
+		}
+		delete(selected, table.Name)
+
+		files, err := Generate(table, cfg)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			file := filepath.Join(out, filepath.FromSlash(name))
+			if _, err = os.Stat(file); err == nil && !force {
+				fmt.Printf("skip %s, already exists\n", file)
+				continue
+			}
+			if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				return err
+			}
+			if err = os.WriteFile(file, files[name], 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("write %s\n", file)
+		}
+		count++
+	}
+
+	if len(selected) > 0 {
+		missing := []string{}
+		for name := range selected {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("tables %s not found in %s", strings.Join(missing, ", "), sqlFile)
+	}
+	if count == 0 {
+		return fmt.Errorf("no CREATE TABLE statement in %s", sqlFile)
+	}
+	return nil
+}
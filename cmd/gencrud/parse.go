@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Table a table of the DDL
+type Table struct {
+	Name       string
+	Columns    []*ColumnDef
+	PrimaryKey []string
+	Comment    string
+}
+
+// ColumnDef a column of a table
+type ColumnDef struct {
+	Name          string
+	Type          string // the full type, example: varchar(40), int(11) unsigned
+	BaseType      string // lower case type without its arguments, example: varchar
+	Unsigned      bool
+	NotNull       bool
+	AutoIncrement bool
+	PrimaryKey    bool
+	Default       string
+	Comment       string
+}
+
+// ParseDDL parse the CREATE TABLE statements of a sql file, the other statements are ignored
+func ParseDDL(ddl string) ([]*Table, error) {
+	tables := []*Table{}
+	for _, statement := range splitStatements(ddl) {
+		tokens := tokenize(statement)
+		if len(tokens) < 2 || !strings.EqualFold(tokens[0], "create") {
+			continue
+		}
+		i := 1
+		if strings.EqualFold(tokens[i], "temporary") {
+			i++
+		}
+		if i >= len(tokens) || !strings.EqualFold(tokens[i], "table") {
+			continue
+		}
+
+		table, err := parseTable(tokens[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// tokens after CREATE TABLE: [IF NOT EXISTS] name ( definitions ) options
+func parseTable(tokens []string) (*Table, error) {
+	if len(tokens) >= 3 && strings.EqualFold(tokens[0], "if") && strings.EqualFold(tokens[1], "not") && strings.EqualFold(tokens[2], "exists") {
+		tokens = tokens[3:]
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("missing table name")
+	}
+
+	table := &Table{Name: unquote(tokens[0])}
+	if i := strings.LastIndexByte(table.Name, '.'); i >= 0 { // schema qualified, example: shop.order
+		table.Name = table.Name[i+1:]
+	}
+	if len(tokens) < 2 || tokens[1] != "(" {
+		return nil, fmt.Errorf("table %s: missing column definitions", table.Name)
+	}
+
+	depth, end := 0, -1
+	for i := 1; i < len(tokens) && end < 0; i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("table %s: unbalanced parentheses", table.Name)
+	}
+
+	for _, definition := range splitTopLevel(tokens[2:end]) {
+		if len(definition) == 0 {
+			continue
+		}
+		if err := table.addDefinition(definition); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("table %s: no columns", table.Name)
+	}
+
+	options := tokens[end+1:]
+	for i := 0; i+1 < len(options); i++ {
+		if strings.EqualFold(options[i], "comment") {
+			j := i + 1
+			if options[j] == "=" && j+1 < len(options) {
+				j++
+			}
+			table.Comment = unquote(options[j])
+		}
+	}
+
+	for _, name := range table.PrimaryKey {
+		if column := table.Column(name); column != nil {
+			column.PrimaryKey = true
+		}
+	}
+	return table, nil
+}
+
+// Column the column by name, nil when not found
+func (t *Table) Column(name string) *ColumnDef {
+	for _, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return column
+		}
+	}
+	return nil
+}
+
+func (t *Table) addDefinition(tokens []string) error {
+	first := strings.ToLower(tokens[0])
+	switch first {
+	case "primary":
+		t.PrimaryKey = append(t.PrimaryKey, keyColumns(tokens)...)
+		return nil
+	case "key", "index", "unique", "constraint", "foreign", "fulltext", "spatial", "check":
+		if first == "constraint" && len(tokens) > 2 && strings.EqualFold(tokens[2], "primary") {
+			t.PrimaryKey = append(t.PrimaryKey, keyColumns(tokens)...)
+		}
+		return nil
+	}
+
+	if len(tokens) < 2 {
+		return fmt.Errorf("column %s: missing type", unquote(tokens[0]))
+	}
+	column := &ColumnDef{Name: unquote(tokens[0]), BaseType: strings.ToLower(tokens[1])}
+	typ := []string{tokens[1]}
+	i := 2
+	if i < len(tokens) && tokens[i] == "(" {
+		for ; i < len(tokens); i++ {
+			typ = append(typ, tokens[i])
+			if tokens[i] == ")" {
+				i++
+				break
+			}
+		}
+	}
+	// multi word types, example: double precision, character varying, timestamp with time zone
+	for ; i < len(tokens); i++ {
+		word := strings.ToLower(tokens[i])
+		if word == "unsigned" || word == "zerofill" || word == "precision" || word == "varying" {
+			column.Unsigned = column.Unsigned || word == "unsigned"
+			typ = append(typ, tokens[i])
+			continue
+		}
+		break
+	}
+	column.Type = joinType(typ)
+
+	for ; i < len(tokens); i++ {
+		word := strings.ToLower(tokens[i])
+		next := ""
+		if i+1 < len(tokens) {
+			next = strings.ToLower(tokens[i+1])
+		}
+		switch {
+		case word == "not" && next == "null":
+			column.NotNull = true
+			i++
+		case word == "auto_increment" || word == "autoincrement":
+			column.AutoIncrement = true
+		case word == "primary" && next == "key":
+			column.PrimaryKey = true
+			column.NotNull = true
+			t.PrimaryKey = append(t.PrimaryKey, column.Name)
+			i++
+		case word == "default" && next != "":
+			i++
+			column.Default = tokens[i]
+			if tokens[i] != "(" && i+1 < len(tokens) && tokens[i+1] == "(" { // a function, example: CURRENT_TIMESTAMP(3)
+				for i++; i < len(tokens); i++ {
+					column.Default += tokens[i]
+					if tokens[i] == ")" {
+						break
+					}
+				}
+			}
+		case word == "comment" && next != "":
+			i++
+			column.Comment = unquote(tokens[i])
+		}
+	}
+	if strings.HasPrefix(column.BaseType, "serial") || strings.HasPrefix(column.BaseType, "bigserial") {
+		column.AutoIncrement = true
+	}
+
+	t.Columns = append(t.Columns, column)
+	return nil
+}
+
+// the columns of PRIMARY KEY (a, b)
+func keyColumns(tokens []string) []string {
+	columns := []string{}
+	depth := 0
+	for _, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+		default:
+			if depth == 1 {
+				columns = append(columns, unquote(token))
+			}
+		}
+		if depth == 0 && len(columns) > 0 {
+			break
+		}
+	}
+	return columns
+}
+
+func joinType(tokens []string) string {
+	s := ""
+	for i, token := range tokens {
+		if i > 0 && token != "(" && token != ")" && token != "," && tokens[i-1] != "(" && tokens[i-1] != "," {
+			s += " "
+		}
+		s += token
+	}
+	return s
+}
+
+// split the definitions at the commas outside parentheses
+func splitTopLevel(tokens []string) [][]string {
+	parts := [][]string{}
+	part := []string{}
+	depth := 0
+	for _, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				parts = append(parts, part)
+				part = []string{}
+				continue
+			}
+		}
+		part = append(part, token)
+	}
+	return append(parts, part)
+}
+
+// split the statements at the semicolons outside quotes and comments
+func splitStatements(ddl string) []string {
+	statements := []string{}
+	start := 0
+	for i := 0; i < len(ddl); i++ {
+		switch c := ddl[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(ddl, i)
+		case c == '-' && strings.HasPrefix(ddl[i:], "--"), c == '#':
+			for i < len(ddl) && ddl[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(ddl[i:], "/*"):
+			if j := strings.Index(ddl[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(ddl)
+			}
+		case c == ';':
+			statements = append(statements, ddl[start:i])
+			start = i + 1
+		}
+	}
+	return append(statements, ddl[start:])
+}
+
+// tokenize a statement into words, quoted strings and identifiers, and punctuation, comments are dropped
+func tokenize(statement string) []string {
+	tokens := []string{}
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '\'' || c == '"' || c == '`':
+			j := skipQuoted(statement, i)
+			// a qualified name, example: `shop`.`order`
+			for c == '`' && j+2 < len(statement) && statement[j+1] == '.' && statement[j+2] == '`' {
+				j = skipQuoted(statement, j+2)
+			}
+			tokens = append(tokens, statement[i:min(j+1, len(statement))])
+			i = j
+		case c == '-' && strings.HasPrefix(statement[i:], "--"), c == '#':
+			for i < len(statement) && statement[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			if j := strings.Index(statement[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(statement)
+			}
+		case c == '(' || c == ')' || c == ',' || c == '=':
+			tokens = append(tokens, string(c))
+		default:
+			j := i
+			for j < len(statement) && !strings.ContainsRune(" \t\n\r(),='\"`", rune(statement[j])) {
+				j++
+			}
+			// a qualified name, example: shop.`order`
+			for j < len(statement) && statement[j] == '`' && j > i && statement[j-1] == '.' {
+				j = skipQuoted(statement, j) + 1
+			}
+			tokens = append(tokens, statement[i:j])
+			i = j - 1
+		}
+	}
+	return tokens
+}
+
+// the index of the closing quote of the quoted string starting at i
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '\\' && quote != '`' {
+			j++
+			continue
+		}
+		if s[j] == quote {
+			if j+1 < len(s) && s[j+1] == quote { // doubled quote
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(s) - 1
+}
+
+// remove the quotes of an identifier or string
+func unquote(s string) string {
+	s = strings.ReplaceAll(s, "`.`", ".")
+	if len(s) >= 2 && (s[0] == '`' || s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		quote := string(s[0])
+		s = strings.ReplaceAll(s[1:len(s)-1], quote+quote, quote)
+		if quote == "'" {
+			s = strings.ReplaceAll(s, `\'`, "'")
+		}
+	}
+	return s
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDDL = `
+-- shop schema; with a semicolon in a comment
+CREATE TABLE IF NOT EXISTS ` + "`shop`.`user`" + ` (
+  ` + "`id`" + ` bigint unsigned NOT NULL AUTO_INCREMENT,
+  ` + "`created_at`" + ` datetime(3) DEFAULT NULL,
+  ` + "`updated_at`" + ` datetime(3) DEFAULT NULL,
+  ` + "`deleted_at`" + ` datetime(3) DEFAULT NULL,
+  ` + "`name`" + ` varchar(40) NOT NULL DEFAULT '' COMMENT 'user''s name; unique',
+  ` + "`age`" + ` int(11) NOT NULL DEFAULT 18,
+  ` + "`score`" + ` decimal(10,2) unsigned DEFAULT NULL,
+  ` + "`status`" + ` enum('active','banned') DEFAULT 'active',
+  ` + "`logged_at`" + ` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (` + "`id`" + `) USING BTREE,
+  UNIQUE KEY ` + "`uk_name`" + ` (` + "`name`" + `),
+  KEY ` + "`idx_deleted_at`" + ` (` + "`deleted_at`" + `)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='users of the shop';
+
+INSERT INTO user (name) VALUES ('a;b');
+
+create table order_items (
+  order_id bigint not null,
+  sku varchar(32) not null,
+  paid_at timestamp null,
+  constraint pk_order_items primary key (order_id, sku)
+);
+CREATE TABLE tags (code char(8) PRIMARY KEY, label text)
+`
+
+func TestParseDDL(t *testing.T) {
+	tables, err := ParseDDL(testDDL)
+	require.NoError(t, err)
+	require.Len(t, tables, 3)
+
+	user := tables[0]
+	assert.Equal(t, "user", user.Name)
+	assert.Equal(t, "users of the shop", user.Comment)
+	assert.Equal(t, []string{"id"}, user.PrimaryKey)
+	assert.Len(t, user.Columns, 9)
+	assert.Equal(t, &ColumnDef{Name: "id", Type: "bigint unsigned", BaseType: "bigint", Unsigned: true, NotNull: true, AutoIncrement: true, PrimaryKey: true}, user.Column("id"))
+	assert.Equal(t, &ColumnDef{Name: "name", Type: "varchar(40)", BaseType: "varchar", NotNull: true, Default: "''", Comment: "user's name; unique"}, user.Column("name"))
+	assert.Equal(t, "int(11)", user.Column("age").Type)
+	assert.Equal(t, "18", user.Column("age").Default)
+	assert.Equal(t, "decimal(10,2) unsigned", user.Column("score").Type)
+	assert.Equal(t, "enum('active','banned')", user.Column("status").Type)
+	assert.Equal(t, "CURRENT_TIMESTAMP(3)", user.Column("logged_at").Default)
+
+	items := tables[1]
+	assert.Equal(t, "order_items", items.Name)
+	assert.Equal(t, []string{"order_id", "sku"}, items.PrimaryKey)
+	assert.True(t, items.Column("sku").PrimaryKey)
+	assert.False(t, items.Column("paid_at").NotNull)
+
+	tags := tables[2]
+	assert.Equal(t, []string{"code"}, tags.PrimaryKey)
+	assert.Equal(t, "text", tags.Column("label").Type)
+
+	_, err = ParseDDL("CREATE TABLE broken (id int")
+	assert.Error(t, err)
+	_, err = ParseDDL("CREATE TABLE empty ()")
+	assert.Error(t, err)
+}
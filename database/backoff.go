@@ -0,0 +1,28 @@
+package database
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff delay before retry number attempt (starting at 1), doubling from min up to max,
+// with a random jitter of up to half the delay so that concurrent retries spread out
+func Backoff(attempt int, min time.Duration, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := Backoff(1, time.Second, time.Minute)
+		assert.True(t, d >= 500*time.Millisecond && d <= time.Second, d)
+
+		d = Backoff(3, time.Second, time.Minute)
+		assert.True(t, d >= 2*time.Second && d <= 4*time.Second, d)
+
+		d = Backoff(30, time.Second, time.Minute)
+		assert.True(t, d >= 30*time.Second && d <= time.Minute, d)
+	}
+	assert.Equal(t, time.Duration(0), Backoff(1, 0, 0))
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-sql-driver/mysql"
+	liblogger "github.com/xingmoo/library/logger"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const defaultPort = 3306
+
+var defaultParams = map[string]string{
+	"charset":   "utf8mb4",
+	"parseTime": "True",
+	"loc":       "Local",
+}
+
+// Config mysql connection settings, zero values use the defaults of Open, example config.yml:
+//
+//	host: 127.0.0.1
+//	port: 3306
+//	user: root
+//	password: "p@ss/word"
+//	database: shop
+//	params:
+//	  timeout: 5s
+//	max_open_conns: 100
+//	slow_threshold: 200ms
+//	log: true
+type Config struct {
+	Host     string            `yaml:"host" toml:"host" json:"host" env:"HOST"`
+	Port     int               `yaml:"port" toml:"port" json:"port" env:"PORT"`
+	User     string            `yaml:"user" toml:"user" json:"user" env:"USER"`
+	Password string            `yaml:"password" toml:"password" json:"password" env:"PASSWORD"`
+	Database string            `yaml:"database" toml:"database" json:"database" env:"DATABASE"`
+	Params   map[string]string `yaml:"params" toml:"params" json:"params" env:"PARAMS"` // dsn parameters, from the environment as k1=v1&k2=v2, charset=utf8mb4, parseTime=True and loc=Local by default
+
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" json:"max_idle_conns" env:"MAX_IDLE_CONNS"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" json:"max_open_conns" env:"MAX_OPEN_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" json:"conn_max_lifetime" env:"CONN_MAX_LIFETIME"`
+
+	Log           bool          `yaml:"log" toml:"log" json:"log" env:"LOG"` // log SQL through the default logger of the logger package
+	SlowThreshold time.Duration `yaml:"slow_threshold" toml:"slow_threshold" json:"slow_threshold" env:"SLOW_THRESHOLD"`
+	QueryTimeout  time.Duration `yaml:"query_timeout" toml:"query_timeout" json:"query_timeout" env:"QUERY_TIMEOUT"`
+	WriteTimeout  time.Duration `yaml:"write_timeout" toml:"write_timeout" json:"write_timeout" env:"WRITE_TIMEOUT"`
+
+	RedactColumns    []string `yaml:"redact_columns" toml:"redact_columns" json:"redact_columns" env:"REDACT_COLUMNS"` // from the environment as c1,c2
+	ParameterizedLog bool     `yaml:"parameterized_log" toml:"parameterized_log" json:"parameterized_log" env:"PARAMETERIZED_LOG"`
+
+	EnableForeignKey bool `yaml:"enable_foreign_key" toml:"enable_foreign_key" json:"enable_foreign_key" env:"ENABLE_FOREIGN_KEY"`
+}
+
+// LoadConfig read the settings from a .yml, .yaml or .toml file and validate them
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return nil, fmt.Errorf("unsupported config format '%s'", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s error, err: %w", file, err)
+	}
+
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadConfigEnv read the settings from environment variables and validate them,
+// the variable names are the prefix followed by the env tag, example: LoadConfigEnv("DB_") reads DB_HOST, DB_PASSWORD ...
+func LoadConfigEnv(prefix string) (*Config, error) {
+	c := &Config{}
+	if err := c.LoadEnv(prefix); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadEnv override the settings with the environment variables that are set, example: overriding the password of a config file
+func (c *Config) LoadEnv(prefix string) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := prefix + t.Field(i).Tag.Get("env")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case []string:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case map[string]string:
+		values, err := url.ParseQuery(value)
+		if err != nil {
+			return err
+		}
+		params := make(map[string]string, len(values))
+		for k := range values {
+			params[k] = values.Get(k)
+		}
+		field.Set(reflect.ValueOf(params))
+	}
+	return nil
+}
+
+// Validate check the settings, all problems are reported at once
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, errors.New("field 'host' cannot be empty"))
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("field 'port' is out of range: %d", c.Port))
+	}
+	if c.User == "" {
+		errs = append(errs, errors.New("field 'user' cannot be empty"))
+	}
+	if c.Database == "" {
+		errs = append(errs, errors.New("field 'database' cannot be empty"))
+	}
+	if c.MaxIdleConns < 0 || c.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("fields 'max_idle_conns' and 'max_open_conns' cannot be negative"))
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("field 'max_idle_conns' (%d) cannot exceed 'max_open_conns' (%d)", c.MaxIdleConns, c.MaxOpenConns))
+	}
+	if c.ConnMaxLifetime < 0 || c.SlowThreshold < 0 || c.QueryTimeout < 0 || c.WriteTimeout < 0 {
+		errs = append(errs, errors.New("durations cannot be negative"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid database config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// DSN build the mysql data source name, the password may contain any character
+func (c *Config) DSN() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	params := make(map[string]string, len(defaultParams)+len(c.Params))
+	for k, v := range defaultParams {
+		params[k] = v
+	}
+	for k, v := range c.Params {
+		params[k] = v
+	}
+
+	mc := mysql.NewConfig()
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(c.Host, strconv.Itoa(port))
+	mc.DBName = c.Database
+	mc.Params = params
+	return mc.FormatDSN()
+}
+
+// Options convert the settings to Open options
+func (c *Config) Options() []Option {
+	opts := []Option{}
+	if c.MaxIdleConns > 0 {
+		opts = append(opts, WithMaxIdleConns(c.MaxIdleConns))
+	}
+	if c.MaxOpenConns > 0 {
+		opts = append(opts, WithMaxOpenConns(c.MaxOpenConns))
+	}
+	if c.ConnMaxLifetime > 0 {
+		opts = append(opts, WithConnMaxLifetime(c.ConnMaxLifetime))
+	}
+	if c.Log {
+		opts = append(opts, WithLog(true, liblogger.Get(), c.SlowThreshold))
+	}
+	if c.QueryTimeout > 0 {
+		opts = append(opts, WithQueryTimeout(c.QueryTimeout))
+	}
+	if c.WriteTimeout > 0 {
+		opts = append(opts, WithWriteTimeout(c.WriteTimeout))
+	}
+	if len(c.RedactColumns) > 0 {
+		opts = append(opts, WithRedactColumns(c.RedactColumns...))
+	}
+	if c.ParameterizedLog {
+		opts = append(opts, WithParameterizedLog())
+	}
+	if c.EnableForeignKey {
+		opts = append(opts, WithEnableForeignKey())
+	}
+	return opts
+}
+
+// OpenConfig validate the settings and open the database, opts are applied after the settings of the config
+func OpenConfig(c *Config, opts ...Option) (*gorm.DB, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return Open(c.DSN(), append(c.Options(), opts...)...)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_DSN(t *testing.T) {
+	c := &Config{
+		Host:     "127.0.0.1",
+		User:     "root",
+		Password: "p@ss:w/ord?&",
+		Database: "shop",
+		Params:   map[string]string{"timeout": "5s", "loc": "UTC"},
+	}
+	dsn := c.DSN()
+
+	mc, err := mysql.ParseDSN(dsn)
+	assert.NoError(t, err)
+	assert.Equal(t, "root", mc.User)
+	assert.Equal(t, "p@ss:w/ord?&", mc.Passwd)
+	assert.Equal(t, "127.0.0.1:3306", mc.Addr)
+	assert.Equal(t, "shop", mc.DBName)
+	assert.True(t, mc.ParseTime)
+	assert.Equal(t, time.UTC, mc.Loc)
+	assert.Equal(t, 5*time.Second, mc.Timeout)
+	assert.Equal(t, "utf8mb4", mc.Params["charset"])
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := &Config{Host: "localhost", User: "root", Database: "shop"}
+	assert.NoError(t, c.Validate())
+
+	c = &Config{Port: 70000, MaxIdleConns: 10, MaxOpenConns: 5}
+	err := c.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'host'")
+	assert.Contains(t, err.Error(), "'port'")
+	assert.Contains(t, err.Error(), "'max_idle_conns' (10)")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yml := filepath.Join(dir, "db.yml")
+	err := os.WriteFile(yml, []byte("host: db\nuser: root\npassword: secret\ndatabase: shop\nmax_open_conns: 20\nslow_threshold: 200ms\nparams:\n  timeout: 5s\n"), 0o600)
+	assert.NoError(t, err)
+	c, err := LoadConfig(yml)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, c.MaxOpenConns)
+	assert.Equal(t, 200*time.Millisecond, c.SlowThreshold)
+	assert.Equal(t, "5s", c.Params["timeout"])
+
+	tml := filepath.Join(dir, "db.toml")
+	err = os.WriteFile(tml, []byte("host = \"db\"\nuser = \"root\"\ndatabase = \"shop\"\nconn_max_lifetime = \"10m\"\n[params]\ntimeout = \"5s\"\n"), 0o600)
+	assert.NoError(t, err)
+	c, err = LoadConfig(tml)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, c.ConnMaxLifetime)
+	assert.Equal(t, "5s", c.Params["timeout"])
+
+	_, err = LoadConfig(filepath.Join(dir, "db.ini"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_USER", "root")
+	t.Setenv("DB_DATABASE", "shop")
+	t.Setenv("DB_PORT", "3307")
+	t.Setenv("DB_LOG", "true")
+	t.Setenv("DB_QUERY_TIMEOUT", "3s")
+	t.Setenv("DB_PARAMS", "timeout=5s&readTimeout=10s")
+	t.Setenv("DB_REDACT_COLUMNS", "password, phone")
+
+	c, err := LoadConfigEnv("DB_")
+	assert.NoError(t, err)
+	assert.Equal(t, 3307, c.Port)
+	assert.True(t, c.Log)
+	assert.Equal(t, 3*time.Second, c.QueryTimeout)
+	assert.Equal(t, map[string]string{"timeout": "5s", "readTimeout": "10s"}, c.Params)
+	assert.Equal(t, []string{"password", "phone"}, c.RedactColumns)
+
+	t.Setenv("DB_PORT", "abc")
+	_, err = LoadConfigEnv("DB_")
+	assert.Error(t, err)
+}
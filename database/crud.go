@@ -2,8 +2,13 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/xingmoo/library/database/query"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 // TableName get table name
@@ -65,3 +70,248 @@ func Count(ctx context.Context, db *gorm.DB, table interface{}, query interface{
 	err := db.WithContext(ctx).Model(table).Where(query, args...).Count(&count).Error
 	return count, err
 }
+
+// ListByParams list the records matching the columns of params, in the page and order of params,
+// with the relations of params.Include preloaded and only the columns of params.Fields selected
+// the param of 'tables' must be pointer to slice, eg: &[]StructName
+func ListByParams(ctx context.Context, db *gorm.DB, tables interface{}, params *query.Params) error {
+	page := query.NewPage(params.Page, params.Size, params.Sort)
+	tx, err := applyParams(db.WithContext(ctx), tables, params, page.SortColumns())
+	if err != nil {
+		return err
+	}
+	return tx.Order(page.Sort()).Limit(page.Size()).Offset(page.Offset()).Find(tables).Error
+}
+
+// GetByParams get the first record matching the columns of params,
+// with the relations of params.Include preloaded and only the columns of params.Fields selected
+// the param of 'table' must be pointer, eg: &StructName
+func GetByParams(ctx context.Context, db *gorm.DB, table interface{}, params *query.Params) error {
+	tx, err := applyParams(db.WithContext(ctx), table, params, nil)
+	if err != nil {
+		return err
+	}
+	return tx.First(table).Error
+}
+
+// add the conditions, preloads and selected columns of params, the columns of the conditions must belong to the model
+func applyParams(db *gorm.DB, model interface{}, params *query.Params, sortColumns []string) (*gorm.DB, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	if params.JSONColumns == nil || params.DateColumns == nil {
+		p := *params
+		if p.JSONColumns == nil {
+			p.JSONColumns = jsonColumns(stmt.Schema)
+		}
+		if p.DateColumns == nil {
+			p.DateColumns = dateColumns(stmt.Schema)
+		}
+		params = &p
+	}
+	if err := params.CheckColumns(stmt.Schema.DBNames); err != nil {
+		return nil, err
+	}
+
+	where, args, err := params.ConvertToGormConditions(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	if where != "" {
+		db = db.Where(where, args...)
+	}
+
+	rels, err := params.ConvertToIncludes(model)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range rels {
+		scope, err := preloadScope(stmt.Schema, rel)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Preload(rel.Field, scope)
+	}
+
+	score, scoreArgs, err := params.ConvertToRelevance(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	sortByScore := containsColumn(sortColumns, query.Relevance)
+	if sortByScore && score == "" {
+		return nil, fmt.Errorf("sort by %s requires a match column", query.Relevance)
+	}
+
+	var columns []string
+	if len(params.Fields) > 0 {
+		columns, err = selectColumns(stmt.Schema, params, rels, sortColumns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if score != "" && (sortByScore || stmt.Schema.LookUpField(query.Relevance) != nil) {
+		if len(columns) == 0 {
+			columns = []string{stmt.Schema.Table + ".*"}
+		}
+		db = db.Select(strings.Join(columns, ",")+", "+score+" AS "+query.Relevance, scoreArgs...)
+	} else if len(columns) > 0 {
+		db = db.Select(columns)
+	}
+
+	return db, nil
+}
+
+// the json columns of the model, their dotted names in the params are json paths, example: attrs.color
+func jsonColumns(sch *schema.Schema) []string {
+	columns := []string{}
+	for _, field := range sch.Fields {
+		dataType := strings.ToLower(string(field.DataType))
+		if field.DBName != "" && (dataType == "json" || dataType == "jsonb" || field.TagSettings["SERIALIZER"] == "json") {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// the date columns of the model, their values in the params may be relative dates, example: created_at with today
+func dateColumns(sch *schema.Schema) []string {
+	columns := []string{}
+	for _, field := range sch.Fields {
+		if field.DBName != "" && field.DataType == schema.Time {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// the requested columns that exist in the model, plus the primary key, the sort columns
+// and the foreign keys needed to preload the included relations
+func selectColumns(sch *schema.Schema, params *query.Params, rels []query.Relation, sortColumns []string) ([]string, error) {
+	columns, err := params.ConvertToFields(sch.DBNames)
+	if err != nil {
+		return nil, err
+	}
+
+	// the relevance score is not a column of the table
+	seen := map[string]bool{query.Relevance: true}
+	filtered := columns[:0]
+	for _, column := range columns {
+		if !seen[column] {
+			seen[column] = true
+			filtered = append(filtered, column)
+		}
+	}
+	columns = filtered
+	add := func(column string) {
+		if !seen[column] && sch.LookUpField(column) != nil {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	for _, column := range sch.PrimaryFieldDBNames {
+		add(column)
+	}
+	for _, column := range sortColumns {
+		add(column)
+	}
+	for _, rel := range rels {
+		relationship, ok := sch.Relationships.Relations[strings.SplitN(rel.Field, ".", 2)[0]]
+		if !ok {
+			continue
+		}
+		for _, ref := range relationship.References {
+			if ref.OwnPrimaryKey {
+				add(ref.PrimaryKey.DBName)
+			} else if ref.ForeignKey.Schema == sch {
+				add(ref.ForeignKey.DBName)
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// the conditions of the preload of a relation, the limit of a has many relation applies to the rows of each parent,
+// with a window function, a limit of a many to many relation is not supported
+func preloadScope(sch *schema.Schema, rel query.Relation) (func(*gorm.DB) *gorm.DB, error) {
+	var relationship *schema.Relationship
+	if rel.Limit > 0 {
+		for _, name := range strings.Split(rel.Field, ".") {
+			r, ok := sch.Relationships.Relations[name]
+			if !ok {
+				return nil, fmt.Errorf("relation '%s' not found in %s", rel.Field, sch.Name)
+			}
+			relationship, sch = r, r.FieldSchema
+		}
+		switch {
+		case relationship.Type == schema.Many2Many:
+			return nil, fmt.Errorf("limit of the many to many relation '%s' is not supported", rel.Name)
+		case relationship.Type != schema.HasMany:
+			relationship = nil // one row per parent
+		case len(sch.PrimaryFieldDBNames) != 1:
+			return nil, fmt.Errorf("limit of the relation '%s' requires a single primary key", rel.Name)
+		}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		if rel.Where != "" {
+			db = db.Where(rel.Where, rel.Args...)
+		}
+		if rel.Order != "" {
+			db = db.Order(rel.Order)
+		}
+		if relationship != nil {
+			sql, args := limitPerParent(db.Statement, relationship, rel)
+			db = db.Where(sql, args...)
+		}
+		return db
+	}, nil
+}
+
+// the condition keeping the first rel.Limit related rows of each parent in the order of the relation,
+// example: id IN (SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY article_id ORDER BY id DESC) AS row_number_
+// FROM comment WHERE status = ?) ranked WHERE row_number_ <= ?)
+func limitPerParent(stmt *gorm.Statement, relationship *schema.Relationship, rel query.Relation) (string, []interface{}) {
+	related := relationship.FieldSchema
+	pk := stmt.Quote(related.PrimaryFieldDBNames[0])
+
+	partition := []string{}
+	for _, ref := range relationship.References {
+		partition = append(partition, stmt.Quote(ref.ForeignKey.DBName))
+	}
+	order := rel.Order
+	if order == "" {
+		order = pk
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if rel.Where != "" {
+		conditions = append(conditions, "("+rel.Where+")")
+		args = append(args, rel.Args...)
+	}
+	for _, field := range related.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) { // soft deleted rows are not ranked
+			conditions = append(conditions, stmt.Quote(field.DBName)+" IS NULL")
+		}
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sql := fmt.Sprintf("%s.%s IN (SELECT %s FROM (SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS row_number_ FROM %s%s) ranked WHERE row_number_ <= ?)",
+		stmt.Quote(related.Table), pk, pk, pk, strings.Join(partition, ", "), order, stmt.Quote(related.Table), where)
+	return sql, append(args, rel.Limit)
+}
@@ -0,0 +1,301 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+)
+
+type authorExample struct {
+	database.Model `gorm:"embedded"`
+	Name           string `gorm:"column:name" json:"name"`
+}
+
+type commentExample struct {
+	database.Model `gorm:"embedded"`
+	ArticleID      uint64         `gorm:"column:article_id" json:"article_id"`
+	AuthorID       uint64         `gorm:"column:author_id" json:"author_id"`
+	Body           string         `gorm:"column:body" json:"body"`
+	Status         int            `gorm:"column:status" json:"status"`
+	Author         *authorExample `json:"author,omitempty"`
+}
+
+type articleExample struct {
+	database.Model `gorm:"embedded"`
+	Title          string           `gorm:"column:title" json:"title"`
+	Body           string           `gorm:"column:body;type:text" json:"body"`
+	AuthorID       uint64           `gorm:"column:author_id" json:"author_id"`
+	Author         *authorExample   `json:"author,omitempty"`
+	Comments       []commentExample `gorm:"foreignKey:ArticleID" json:"comments,omitempty"`
+}
+
+func TestListByParams_include(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &authorExample{}, &articleExample{}, &commentExample{})
+	query.AllowIncludes(&articleExample{},
+		query.Relation{Name: "author"},
+		query.Relation{Name: "comments", Where: "status = ?", Args: []interface{}{1}, Order: "id DESC"},
+		query.Relation{Name: "comments.author"},
+	)
+
+	alice, bob := &authorExample{Name: "Alice"}, &authorExample{Name: "Bob"}
+	assert.NoError(t, database.Create(ctx, db, alice))
+	assert.NoError(t, database.Create(ctx, db, bob))
+	article := &articleExample{Title: "Hello", AuthorID: alice.ID}
+	assert.NoError(t, database.Create(ctx, db, article))
+	for i, status := range []int{1, 0, 1} {
+		comment := &commentExample{ArticleID: article.ID, AuthorID: bob.ID, Body: string(rune('a' + i)), Status: status}
+		assert.NoError(t, database.Create(ctx, db, comment))
+	}
+
+	params := &query.Params{Size: 10, Include: []string{"author,comments.author"}}
+	articles := []articleExample{}
+	assert.NoError(t, database.ListByParams(ctx, db, &articles, params))
+	assert.Len(t, articles, 1)
+	assert.Equal(t, "Alice", articles[0].Author.Name)
+	assert.Len(t, articles[0].Comments, 2)
+	assert.Equal(t, "c", articles[0].Comments[0].Body)
+	assert.Equal(t, "Bob", articles[0].Comments[0].Author.Name)
+
+	got := &articleExample{}
+	params = &query.Params{Columns: []query.Column{{Name: "id", Value: article.ID}}, Include: []string{"author"}}
+	assert.NoError(t, database.GetByParams(ctx, db, got, params))
+	assert.Equal(t, "Alice", got.Author.Name)
+	assert.Nil(t, got.Comments)
+
+	params = &query.Params{Size: 10, Include: []string{"author.articles"}}
+	assert.Error(t, database.ListByParams(ctx, db, &articles, params))
+}
+
+func TestListByParams_fields(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &authorExample{}, &articleExample{})
+	query.AllowIncludes(&articleExample{}, query.Relation{Name: "author"})
+
+	alice := &authorExample{Name: "Alice"}
+	assert.NoError(t, database.Create(ctx, db, alice))
+	assert.NoError(t, database.Create(ctx, db, &articleExample{Title: "Hello", Body: "long text", AuthorID: alice.ID}))
+	assert.NoError(t, database.Create(ctx, db, &articleExample{Title: "World", Body: "long text", AuthorID: alice.ID}))
+
+	// the primary key and the sort column are always selected
+	params := &query.Params{Size: 10, Sort: "-created_at", Fields: []string{"title"}}
+	articles := []articleExample{}
+	assert.NoError(t, database.ListByParams(ctx, db, &articles, params))
+	assert.Len(t, articles, 2)
+	assert.Equal(t, "World", articles[0].Title)
+	assert.NotZero(t, articles[0].ID)
+	assert.False(t, articles[0].CreatedAt.IsZero())
+	assert.Empty(t, articles[0].Body)
+	assert.Zero(t, articles[0].AuthorID)
+
+	// the foreign key of an included relation is selected
+	got := &articleExample{}
+	params = &query.Params{Columns: []query.Column{{Name: "title", Value: "Hello"}}, Fields: []string{"title"}, Include: []string{"author"}}
+	assert.NoError(t, database.GetByParams(ctx, db, got, params))
+	assert.Equal(t, "Alice", got.Author.Name)
+	assert.Empty(t, got.Body)
+
+	params = &query.Params{Size: 10, Fields: []string{"title,secret"}}
+	assert.Error(t, database.ListByParams(ctx, db, &articles, params))
+}
+
+type productExample struct {
+	database.Model `gorm:"embedded"`
+	Name           string `gorm:"column:name" json:"name"`
+	Attrs          string `gorm:"column:attrs;type:json" json:"attrs"`
+}
+
+func TestListByParams_json(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &productExample{})
+	assert.NoError(t, database.Create(ctx, db, &productExample{Name: "shirt", Attrs: `{"color":"red","size":40,"tags":["sale","new"]}`}))
+	assert.NoError(t, database.Create(ctx, db, &productExample{Name: "shoe", Attrs: `{"color":"blue","size":42,"tags":["new"]}`}))
+
+	list := func(columns ...query.Column) []string {
+		products := []productExample{}
+		assert.NoError(t, database.ListByParams(ctx, db, &products, &query.Params{Size: 10, Sort: "id", Columns: columns}))
+		names := []string{}
+		for _, product := range products {
+			names = append(names, product.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"shirt"}, list(query.Column{Name: "attrs.color", Value: "red"}))
+	assert.Equal(t, []string{"shoe"}, list(query.Column{Name: "attrs->$.size", Exp: query.Gt, Value: 40}))
+	assert.Equal(t, []string{"shirt"}, list(query.Column{Name: "attrs.tags", Exp: query.Contains, Value: "sale"}))
+	assert.Equal(t, []string{"shirt", "shoe"}, list(query.Column{Name: "attrs.tags[0]", Value: "sale", Logic: query.OR},
+		query.Column{Name: "attrs.tags[0]", Value: "new"}))
+
+	params := &query.Params{Size: 10, Columns: []query.Column{{Name: "secret.color", Value: "red"}}}
+	assert.Error(t, database.ListByParams(ctx, db, &[]productExample{}, params))
+}
+
+func TestList_cond(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &productExample{})
+	for _, name := range []string{"shirt", "shoe", "hat"} {
+		assert.NoError(t, database.Create(ctx, db, &productExample{Name: name, Attrs: "{}"}))
+	}
+
+	cond := query.Where(query.Col("id").Gt(0), query.Or(query.Col("name").Like("sh"), query.Col("name").Eq("hat")), query.Not(query.Col("name").Eq("shoe")))
+	products := []productExample{}
+	assert.NoError(t, database.List(ctx, db, &products, query.NewPage(0, 10, "id"), cond))
+	assert.Len(t, products, 2)
+	assert.Equal(t, "shirt", products[0].Name)
+	assert.Equal(t, "hat", products[1].Name)
+
+	count, err := database.Count(ctx, db, &productExample{}, query.Col("name").In("hat", "shoe"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = database.Count(ctx, db, &productExample{}, query.Where())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	_, err = database.Count(ctx, db, &productExample{}, query.Col("name OR 1").Eq(1))
+	assert.Error(t, err)
+}
+
+func TestListByParams_filter(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &productExample{})
+	assert.NoError(t, database.Create(ctx, db, &productExample{Name: "shirt", Attrs: `{"color":"red","size":40}`}))
+	assert.NoError(t, database.Create(ctx, db, &productExample{Name: "shoe", Attrs: `{"color":"blue","size":42}`}))
+	assert.NoError(t, database.Create(ctx, db, &productExample{Name: "hat", Attrs: `{"color":"red","size":1}`}))
+
+	products := []productExample{}
+	params := &query.Params{Size: 10, Sort: "id", Filter: `attrs.color = "red" and (name ~ "sh" or attrs.size < 10)`}
+	assert.NoError(t, database.ListByParams(ctx, db, &products, params))
+	assert.Len(t, products, 2)
+	assert.Equal(t, "shirt", products[0].Name)
+	assert.Equal(t, "hat", products[1].Name)
+
+	params.Filter = `secret = 1`
+	assert.EqualError(t, database.ListByParams(ctx, db, &products, params), "filter error at position 1: column 'secret' cannot be queried")
+}
+
+type postExample struct {
+	database.Model `gorm:"embedded"`
+	Title          string  `gorm:"column:title" json:"title"`
+	Body           string  `gorm:"column:body;type:text" json:"body"`
+	Relevance      float64 `gorm:"column:relevance;->;-:migration" json:"relevance"`
+}
+
+func TestListByParams_match(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &postExample{})
+	assert.NoError(t, database.Create(ctx, db, &postExample{Title: "java tips", Body: "go and java"}))
+	assert.NoError(t, database.Create(ctx, db, &postExample{Title: "go tips", Body: "go web server"}))
+	assert.NoError(t, database.Create(ctx, db, &postExample{Title: "rust", Body: "go channels"}))
+	assert.NoError(t, database.Create(ctx, db, &postExample{Title: "python", Body: "web"}))
+
+	posts := []postExample{}
+	params := &query.Params{Size: 10, Sort: "-relevance,id", Columns: []query.Column{
+		{Name: "title,body", Exp: query.Match, Value: "+go -java"},
+	}}
+	assert.NoError(t, database.ListByParams(ctx, db, &posts, params))
+	assert.Len(t, posts, 2)
+	assert.Equal(t, "go tips", posts[0].Title)
+	assert.Equal(t, float64(2), posts[0].Relevance)
+	assert.Equal(t, "rust", posts[1].Title)
+	assert.Equal(t, float64(1), posts[1].Relevance)
+
+	params.Fields = []string{"title", "relevance"}
+	assert.NoError(t, database.ListByParams(ctx, db, &posts, params))
+	assert.Len(t, posts, 2)
+	assert.Equal(t, "go tips", posts[0].Title)
+	assert.Empty(t, posts[0].Body)
+	assert.Equal(t, float64(2), posts[0].Relevance)
+
+	post := &postExample{}
+	assert.NoError(t, database.GetByParams(ctx, db, post, &query.Params{Filter: `title match "rust"`}))
+	assert.Equal(t, "rust", post.Title)
+	assert.Equal(t, float64(1), post.Relevance)
+	// the wildcards of like are searched literally
+	assert.ErrorIs(t, database.GetByParams(ctx, db, &postExample{}, &query.Params{Filter: `title match "r_st"`}), query.ErrNotFound)
+
+	params = &query.Params{Size: 10, Sort: "-relevance"}
+	assert.EqualError(t, database.ListByParams(ctx, db, &posts, params), "sort by relevance requires a match column")
+}
+
+func TestListByParams_relativeDate(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &authorExample{})
+	now := time.Now().UTC()
+	for i, age := range []time.Duration{time.Hour, 3 * 24 * time.Hour, 40 * 24 * time.Hour} {
+		author := &authorExample{Name: fmt.Sprintf("author%d", i)}
+		author.CreatedAt = now.Add(-age)
+		assert.NoError(t, database.Create(ctx, db, author))
+	}
+
+	authors := []authorExample{}
+	params := &query.Params{Size: 10, Sort: "id", Timezone: "UTC", Filter: `created_at between "-30d..now"`}
+	assert.NoError(t, database.ListByParams(ctx, db, &authors, params))
+	assert.Len(t, authors, 2)
+
+	params.Filter = `created_at < "date:-30d"`
+	assert.NoError(t, database.ListByParams(ctx, db, &authors, params))
+	assert.Len(t, authors, 1)
+	assert.Equal(t, "author2", authors[0].Name)
+
+	// created_at is a date column of the model, its relative dates need no prefix
+	params = &query.Params{Size: 10, Sort: "id", Timezone: "UTC", Columns: []query.Column{{Name: "created_at", Exp: query.Gte, Value: "-2d"}}}
+	assert.NoError(t, database.ListByParams(ctx, db, &authors, params))
+	assert.Len(t, authors, 1)
+	assert.Equal(t, "author0", authors[0].Name)
+
+	params.Columns = nil
+	params.Filter = `created_at < "-30d"`
+	assert.NoError(t, database.ListByParams(ctx, db, &authors, params))
+	assert.Len(t, authors, 1)
+	assert.Equal(t, "author2", authors[0].Name)
+}
+
+type threadExample struct {
+	database.Model `gorm:"embedded"`
+	Title          string         `gorm:"column:title" json:"title"`
+	Replies        []replyExample `gorm:"foreignKey:ThreadID" json:"replies,omitempty"`
+}
+
+type replyExample struct {
+	database.Model `gorm:"embedded"`
+	ThreadID       uint64 `gorm:"column:thread_id" json:"thread_id"`
+	Body           string `gorm:"column:body" json:"body"`
+}
+
+func TestListByParams_includeLimit(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &threadExample{}, &replyExample{})
+	query.AllowIncludes(&threadExample{}, query.Relation{Name: "replies", Order: "id DESC", Limit: 2})
+
+	for _, title := range []string{"a", "b"} {
+		thread := &threadExample{Title: title}
+		assert.NoError(t, database.Create(ctx, db, thread))
+		for i := 1; i <= 3; i++ {
+			assert.NoError(t, database.Create(ctx, db, &replyExample{ThreadID: thread.ID, Body: fmt.Sprintf("%s%d", title, i)}))
+		}
+	}
+	// a soft deleted reply is not counted
+	assert.NoError(t, database.Delete(ctx, db, &replyExample{}, "body = ?", "b3"))
+
+	threads := []threadExample{}
+	params := &query.Params{Size: 10, Sort: "id", Include: []string{"replies"}}
+	assert.NoError(t, database.ListByParams(ctx, db, &threads, params))
+	assert.Len(t, threads, 2)
+	bodies := func(replies []replyExample) []string {
+		s := []string{}
+		for _, reply := range replies {
+			s = append(s, reply.Body)
+		}
+		return s
+	}
+	assert.Equal(t, []string{"a3", "a2"}, bodies(threads[0].Replies))
+	assert.Equal(t, []string{"b2", "b1"}, bodies(threads[1].Replies))
+}
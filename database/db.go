@@ -27,10 +27,57 @@ func Open(dns string, opts ...Option) (*gorm.DB, error) {
 		return nil, fmt.Errorf("gorm.Open error, err: %w", err)
 	}
 	db.Set("gorm:table_options", "CHARSET=utf8mb4") // automatic appending of table suffixes when creating tables
+	if err = setup(db, sqlDB, o); err != nil {
+		return nil, err
+	}
 
 	return db, nil
 }
 
+// OpenDialector open a database through any gorm dialector, with the same gorm settings and connection pool options as Open
+func OpenDialector(dialector gorm.Dialector, opts ...Option) (*gorm.DB, error) {
+	o := defaultOptions()
+	o.apply(opts...)
+
+	db, err := gorm.Open(dialector, gormConfig(o))
+	if err != nil {
+		return nil, fmt.Errorf("gorm.Open error, err: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxIdleConns(o.maxIdleConns)
+	sqlDB.SetMaxOpenConns(o.maxOpenConns)
+	sqlDB.SetConnMaxLifetime(o.connMaxLifetime)
+	if err = setup(db, sqlDB, o); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// register the callbacks of the library on an opened database, shared by Open and OpenDialector
+func setup(db *gorm.DB, sqlDB *sql.DB, o *options) error {
+	if err := registerExplainCallbacks(db, sqlDB); err != nil {
+		return err
+	}
+	if err := registerTimeoutCallbacks(db, o); err != nil {
+		return err
+	}
+	if err := registerErrorCallbacks(db); err != nil {
+		return err
+	}
+	if err := registerDiffCallback(db); err != nil {
+		return err
+	}
+	if err := registerRedactCallbacks(db); err != nil {
+		return err
+	}
+	return registerPolicyCallbacks(db)
+}
+
 // gorm setting
 func gormConfig(o *options) *gorm.Config {
 	config := &gorm.Config{
@@ -44,7 +91,16 @@ func gormConfig(o *options) *gorm.Config {
 
 	// print all SQL
 	if o.enableLogin {
-		config.Logger = &logger{logger: o.logger, slowThreshold: o.slowThreshold}
+		l := &logger{
+			logger:        o.logger,
+			slowThreshold: o.slowThreshold,
+			redactor:      newRedactor(o.redactColumns, o.redactPatterns),
+			parameterized: o.parameterizedLog,
+		}
+		if o.explainFormat != "" {
+			l.explainer = newExplainer(o.explainFormat, o.explainInterval)
+		}
+		config.Logger = l
 	}
 
 	return config
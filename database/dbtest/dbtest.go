@@ -0,0 +1,90 @@
+// Package dbtest provides in-memory SQLite databases for repository tests.
+//
+// Every database is opened with the gorm settings of the database package (naming strategy, logger),
+// so table names and queries behave the same as in production, example:
+//
+//	func TestCreateUser(t *testing.T) {
+//		db := dbtest.New(t, &User{})
+//		err := database.Create(ctx, db, &User{Name: "Alice"})
+//		...
+//	}
+//
+// Tests sharing one database can isolate their writes with Tx, all changes are rolled back when the test finishes.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/xingmoo/library/database"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// every connection to ":memory:" is a separate database, so the pool is kept to a single connection that never expires
+const dsn = ":memory:"
+
+// New open an in-memory database with the models auto-migrated, the SQL is logged through t.Log,
+// the database is closed when the test finishes
+func New(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := open(zaptest.NewLogger(t), models...)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// Open an in-memory database with the models auto-migrated that is not bound to a test,
+// it is meant to be shared by several tests, example in TestMain, combined with Tx.
+// the caller closes the database.
+func Open(models ...interface{}) (*gorm.DB, error) {
+	return open(zap.NewNop(), models...)
+}
+
+// Tx begin a transaction on a shared database and roll it back when the test finishes,
+// the test must run all its queries on the returned db.
+func Tx(t testing.TB, db *gorm.DB) *gorm.DB {
+	t.Helper()
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("dbtest: begin transaction error, err: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+
+	return tx
+}
+
+func open(zlogger *zap.Logger, models ...interface{}) (*gorm.DB, error) {
+	db, err := database.OpenDialector(sqlite.Open(dsn),
+		database.WithMaxIdleConns(1),
+		database.WithMaxOpenConns(1),
+		database.WithConnMaxLifetime(0),
+		database.WithLog(true, zlogger, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(models) > 0 {
+		if err = db.AutoMigrate(models...); err != nil {
+			if sqlDB, e := db.DB(); e == nil {
+				_ = sqlDB.Close()
+			}
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
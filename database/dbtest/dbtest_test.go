@@ -0,0 +1,64 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/query"
+)
+
+type userExample struct {
+	database.Model `gorm:"embedded"`
+	Name           string `gorm:"column:name"`
+	Age            int    `gorm:"column:age"`
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	db := New(t, &userExample{})
+	assert.True(t, db.Migrator().HasTable("user_example"))
+
+	err := database.Create(ctx, db, &userExample{Name: "Alice", Age: 20})
+	assert.NoError(t, err)
+	err = database.Create(ctx, db, &userExample{Name: "Bob", Age: 30})
+	assert.NoError(t, err)
+
+	user := &userExample{}
+	err = database.Get(ctx, db, user, "name = ?", "Bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, user.Age)
+
+	users := []userExample{}
+	err = database.List(ctx, db, &users, query.NewPage(0, 10, "-age"), "age > ?", 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "Bob", users[0].Name)
+
+	err = database.GetByID(ctx, db, &userExample{}, 100)
+	assert.ErrorIs(t, err, query.ErrNotFound)
+}
+
+func TestTx(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(&userExample{})
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := db.DB()
+		_ = sqlDB.Close()
+	}()
+
+	t.Run("insert", func(t *testing.T) {
+		tx := Tx(t, db)
+		err := database.Create(ctx, tx, &userExample{Name: "Alice"})
+		assert.NoError(t, err)
+		count, err := database.Count(ctx, tx, &userExample{}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	count, err := database.Count(ctx, db, &userExample{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+var (
+	modelsMu sync.Mutex
+	models   []interface{}
+)
+
+// RegisterModels add models to the schema compared by Diff, example: database.RegisterModels(&User{}, &Order{})
+func RegisterModels(values ...interface{}) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models = append(models, values...)
+}
+
+// Models return the registered models
+func Models() []interface{} {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	return append([]interface{}{}, models...)
+}
+
+type recorderKey struct{}
+
+// recorder collect the statements executed by the migrator instead of running them
+type recorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *recorder) add(statement string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, statement)
+}
+
+// Diff compare the schemas of the models with the live database and return the DDL statements
+// AutoMigrate would execute, nothing is changed, the registered models are used when none are passed.
+// the naming strategy and the foreign key option of db apply, as in a real migration.
+// the database is read to find the differences, the statements that depend on a table or column
+// created by an earlier statement of the diff are not known until that statement runs.
+// db must be opened by Open or OpenDialector, which install the recorder of the statements, Diff fails otherwise
+func Diff(ctx context.Context, db *gorm.DB, values ...interface{}) ([]string, error) {
+	if len(values) == 0 {
+		values = Models()
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	// without the recorder the migration would really run
+	if _, ok := db.Config.Plugins[diffPluginName]; !ok {
+		return nil, errors.New("diff requires a db opened by database.Open or OpenDialector, the statements would be executed")
+	}
+
+	rec := &recorder{}
+	ctx = context.WithValue(ctx, recorderKey{}, rec)
+	if err := db.WithContext(ctx).AutoMigrate(values...); err != nil {
+		return nil, fmt.Errorf("diff schema error, err: %w", err)
+	}
+	return rec.statements, nil
+}
+
+// WriteDiff write the statements of Diff to w, one per line terminated by a semicolon, example to review them:
+//
+//	err := database.WriteDiff(ctx, os.Stdout, db)
+func WriteDiff(ctx context.Context, w io.Writer, db *gorm.DB, values ...interface{}) error {
+	statements, err := Diff(ctx, db, values...)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err = fmt.Fprintf(w, "%s;\n", strings.TrimSuffix(strings.TrimSpace(statement), ";")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const diffPluginName = "database:diff"
+
+// the migrator runs its DDL through Exec, the statements of a Diff are recorded instead of executed,
+// the queries reading the current schema are not affected.
+// installed as a plugin so that Diff can check it is present
+type diffPlugin struct{}
+
+func (diffPlugin) Name() string {
+	return diffPluginName
+}
+
+func (diffPlugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Raw().Replace("gorm:raw", func(db *gorm.DB) {
+		if rec, ok := db.Statement.Context.Value(recorderKey{}).(*recorder); ok {
+			if db.Error == nil {
+				rec.add(db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+			}
+			return
+		}
+		callbacks.RawExec(db)
+	})
+}
+
+func registerDiffCallback(db *gorm.DB) error {
+	return db.Use(diffPlugin{})
+}
@@ -0,0 +1,79 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type customerExample struct {
+	ID   uint64 `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	Name string `gorm:"column:name;type:varchar(100)" json:"name"`
+}
+
+// customerExample with a new column
+type customerV2Example struct {
+	ID    uint64 `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	Name  string `gorm:"column:name;type:varchar(100)" json:"name"`
+	Email string `gorm:"column:email;type:varchar(255);index:idx_customer_email" json:"email"`
+}
+
+func (customerV2Example) TableName() string {
+	return "customer_example"
+}
+
+type invoiceExample struct {
+	ID         uint64           `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	CustomerID uint64           `gorm:"column:customer_id" json:"customer_id"`
+	Customer   *customerExample `json:"customer,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &customerExample{})
+
+	statements, err := database.Diff(ctx, db, &customerV2Example{}, &invoiceExample{})
+	assert.NoError(t, err)
+	assert.Len(t, statements, 3)
+	assert.Equal(t, "ALTER TABLE `customer_example` ADD `email` varchar(255)", statements[0])
+	assert.Equal(t, "CREATE INDEX `idx_customer_email` ON `customer_example`(`email`)", statements[1])
+	assert.True(t, strings.HasPrefix(statements[2], "CREATE TABLE `invoice_example`"), statements[2])
+	assert.NotContains(t, statements[2], "CONSTRAINT") // foreign keys are disabled by default
+
+	// nothing was executed
+	assert.False(t, db.Migrator().HasTable(&invoiceExample{}))
+	assert.False(t, db.Migrator().HasColumn(&customerV2Example{}, "email"))
+
+	// up to date
+	statements, err = database.Diff(ctx, db, &customerExample{})
+	assert.NoError(t, err)
+	assert.Empty(t, statements)
+
+	database.RegisterModels(&customerV2Example{})
+	buf := &bytes.Buffer{}
+	assert.NoError(t, database.WriteDiff(ctx, buf, db))
+	assert.Equal(t, "ALTER TABLE `customer_example` ADD `email` varchar(255);\n"+
+		"CREATE INDEX `idx_customer_email` ON `customer_example`(`email`);\n", buf.String())
+
+	// regular statements still run
+	assert.NoError(t, db.Exec("INSERT INTO customer_example (name) VALUES (?)", "Alice").Error)
+	count, err := database.Count(ctx, db, &customerExample{}, "name = ?", "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDiff_withoutRecorder(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	_, err = database.Diff(context.Background(), db, &customerExample{})
+	assert.Error(t, err)
+	assert.False(t, db.Migrator().HasTable(&customerExample{}))
+}
@@ -0,0 +1,410 @@
+// Package encrypt provides transparent AES-GCM column encryption through gorm serializers.
+//
+// Register a cipher once at startup, then tag the sensitive fields:
+//
+//	keyring, _ := encrypt.NewStaticKeyring("2026-01", map[string][]byte{"2026-01": key})
+//	encrypt.Register(encrypt.New(keyring, encrypt.WithBlindIndexKey(indexKey)))
+//
+//	type User struct {
+//		database.Model `gorm:"embedded"`
+//		Phone          string `gorm:"column:phone;serializer:encrypted"`
+//		PhoneIndex     string `gorm:"column:phone_bidx;index;serializer:blindindex;blindindex:Phone"`
+//	}
+//
+// The stored value is enc:v1:<key id>:<base64 nonce and ciphertext>, values without this prefix are read as plaintext,
+// so existing rows can be encrypted by reading and saving them again. The table and column names are authenticated
+// with the value, a value copied to another column does not decrypt.
+// The blind index column holds a keyed hash of the source field that allows equality lookups, example:
+//
+//	column, err := encrypt.Column("phone_bidx", "13800138000")
+//	params.Columns = append(params.Columns, column)
+//
+// Updates with a struct skip its zero fields, including the blind index of a changed source field,
+// register the cipher as a gorm plugin to keep the blind indexes of these updates current:
+//
+//	if err := db.Use(cipher); err != nil {
+//		return err
+//	}
+//
+// Values written through a map, such as database.Updates, bypass the serializers and must be encrypted with Cipher.Encrypt.
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// SerializerName name of the encrypting serializer, example: `gorm:"serializer:encrypted"`
+	SerializerName = "encrypted"
+	// BlindIndexSerializerName name of the blind index serializer, the source field is set by the blindindex tag,
+	// example: `gorm:"serializer:blindindex;blindindex:Phone"`
+	BlindIndexSerializerName = "blindindex"
+
+	prefix    = "enc:v1:"
+	separator = ':'
+)
+
+var (
+	// ErrNoBlindIndexKey the cipher has no blind index key
+	ErrNoBlindIndexKey = errors.New("blind index key is not set")
+
+	mu            sync.RWMutex
+	defaultCipher *Cipher
+)
+
+// Cipher encrypt and decrypt column values with the keys of a keyring
+type Cipher struct {
+	keyring  Keyring
+	indexKey []byte
+}
+
+// Option set the cipher options.
+type Option func(*Cipher)
+
+// WithBlindIndexKey set the HMAC key of blind indexes, it must not change once indexes have been written
+func WithBlindIndexKey(key []byte) Option {
+	return func(c *Cipher) {
+		c.indexKey = key
+	}
+}
+
+// New create a cipher
+func New(keyring Keyring, opts ...Option) *Cipher {
+	c := &Cipher{keyring: keyring}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register the encrypted and blindindex serializers with gorm, using cipher
+func Register(c *Cipher) {
+	mu.Lock()
+	defaultCipher = c
+	mu.Unlock()
+
+	schema.RegisterSerializer(SerializerName, serializer{cipher: c})
+	schema.RegisterSerializer(BlindIndexSerializerName, blindIndexSerializer{cipher: c})
+}
+
+// BlindIndex compute the blind index of value with the registered cipher
+func BlindIndex(value string) (string, error) {
+	mu.RLock()
+	c := defaultCipher
+	mu.RUnlock()
+
+	if c == nil {
+		return "", errors.New("no cipher registered")
+	}
+	return c.BlindIndex(value)
+}
+
+// Column equality condition on a blind index column, the value is hashed with the registered cipher,
+// an error is returned when no cipher is registered or it has no blind index key
+func Column(name string, value string) (query.Column, error) {
+	index, err := BlindIndex(value)
+	if err != nil {
+		return query.Column{}, fmt.Errorf("blind index of column '%s' error, err: %w", name, err)
+	}
+	return query.Column{Name: name, Exp: query.Eq, Value: index}, nil
+}
+
+// Encrypt plaintext of the column of table with the current key
+func (c *Cipher) Encrypt(table string, column string, plaintext []byte) (string, error) {
+	id, key, err := c.keyring.Current()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData(table, column))
+
+	return prefix + id + string(separator) + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt a value produced by Encrypt for the same column of table, with the key it was written with
+func (c *Cipher) Decrypt(table string, column string, ciphertext string) ([]byte, error) {
+	if !IsEncrypted(ciphertext) {
+		return nil, errors.New("value is not encrypted")
+	}
+	s := ciphertext[len(prefix):]
+	i := strings.IndexByte(s, separator)
+	if i < 0 {
+		return nil, errors.New("malformed encrypted value")
+	}
+	id, encoded := s[:i], s[i+1:]
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value, err: %w", err)
+	}
+	key, err := c.keyring.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData(table, column))
+}
+
+// Name of the gorm plugin
+func (c *Cipher) Name() string {
+	return "library:encrypt"
+}
+
+// Initialize register the callback that adds the blind indexes of the changed source fields to struct updates
+func (c *Cipher) Initialize(db *gorm.DB) error {
+	return db.Callback().Update().Before("gorm:update").Register("library:encrypt_blind_index", c.updateBlindIndexes)
+}
+
+// a struct update skips its zero fields, the blind index of a non-zero source field is set in the struct so that it
+// is updated, and the blind index of a selected source field is selected too
+func (c *Cipher) updateBlindIndexes(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	dest := reflect.ValueOf(stmt.Dest)
+	for dest.Kind() == reflect.Ptr {
+		dest = dest.Elem()
+	}
+	if dest.Kind() != reflect.Struct || dest.Type() != stmt.Schema.ModelType {
+		return // a map bypasses the serializers
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.TagSettings["SERIALIZER"] != BlindIndexSerializerName || field.DBName == "" {
+			continue
+		}
+		source := stmt.Schema.LookUpField(field.TagSettings["BLINDINDEX"])
+		if source == nil {
+			continue // reported by the serializer
+		}
+
+		if len(stmt.Selects) > 0 {
+			if selected(stmt.Selects, source) && !selected(stmt.Selects, field) {
+				stmt.Selects = append(stmt.Selects, field.DBName)
+			}
+			continue
+		}
+		value := source.ReflectValueOf(stmt.Context, dest)
+		if value.IsZero() || !field.ReflectValueOf(stmt.Context, dest).IsZero() {
+			continue
+		}
+		plaintext, ok, err := encode(value.Interface())
+		if err != nil || !ok {
+			_ = db.AddError(err)
+			return
+		}
+		index, err := c.BlindIndex(string(plaintext))
+		if err != nil {
+			_ = db.AddError(fmt.Errorf("blind index column '%s' error, err: %w", field.DBName, err))
+			return
+		}
+		if !dest.CanAddr() { // Updates of a struct value, the copy is updated
+			ptr := reflect.New(dest.Type())
+			ptr.Elem().Set(dest)
+			stmt.Dest, dest = ptr.Interface(), ptr.Elem()
+		}
+		if err = field.Set(stmt.Context, dest, index); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
+
+func selected(selects []string, field *schema.Field) bool {
+	for _, s := range selects {
+		if s == "*" || s == field.DBName || s == field.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// BlindIndex keyed hash of value, equal values have equal indexes
+func (c *Cipher) BlindIndex(value string) (string, error) {
+	if len(c.indexKey) == 0 {
+		return "", ErrNoBlindIndexKey
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value)) //nolint
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+// IsEncrypted report whether the value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// the names are separated by a byte they cannot contain
+func additionalData(table string, column string) []byte {
+	return []byte(table + "\x00" + column)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type serializer struct {
+	cipher *Cipher
+}
+
+// Scan decrypt the column value into the field
+func (s serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	var data string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		data = string(v)
+	case string:
+		data = v
+	default:
+		return fmt.Errorf("failed to decrypt value: %#v", dbValue)
+	}
+
+	if data != "" {
+		plaintext := []byte(data) // legacy plaintext value
+		if IsEncrypted(data) {
+			var err error
+			plaintext, err = s.cipher.Decrypt(field.Schema.Table, field.DBName, data)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt column '%s', err: %w", field.DBName, err)
+			}
+		}
+		if err := decode(plaintext, fieldValue); err != nil {
+			return fmt.Errorf("failed to decode column '%s', err: %w", field.DBName, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value encrypt the field value, empty values are stored as they are
+func (s serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok, err := encode(fieldValue)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if len(plaintext) == 0 {
+		return "", nil
+	}
+	return s.cipher.Encrypt(field.Schema.Table, field.DBName, plaintext)
+}
+
+// strings and bytes are stored as they are, other types as json
+func encode(v interface{}) ([]byte, bool, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch {
+	case !rv.IsValid():
+		return nil, false, nil
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), true, nil
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.IsNil():
+		return nil, false, nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), true, nil
+	}
+
+	data, err := json.Marshal(rv.Interface())
+	return data, true, err
+}
+
+func decode(data []byte, ptr reflect.Value) error {
+	v := ptr.Elem()
+	for v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(data))
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(data)
+		return nil
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
+
+type blindIndexSerializer struct {
+	cipher *Cipher
+}
+
+// Scan keep the stored index
+func (s blindIndexSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var index string
+	switch v := dbValue.(type) {
+	case []byte:
+		index = string(v)
+	case string:
+		index = v
+	}
+	return field.Set(ctx, dst, index)
+}
+
+// Value compute the index from the source field named by the blindindex tag
+func (s blindIndexSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	name := field.TagSettings["BLINDINDEX"]
+	source := field.Schema.LookUpField(name)
+	if source == nil {
+		return nil, fmt.Errorf("blind index column '%s': unknown source field '%s'", field.DBName, name)
+	}
+
+	// ValueOf of a serialized source field returns the serializer, the raw field is read instead
+	value := source.ReflectValueOf(ctx, dst)
+	if value.IsZero() {
+		return nil, nil
+	}
+	plaintext, ok, err := encode(value.Interface())
+	if err != nil || !ok {
+		return nil, err
+	}
+	return s.cipher.BlindIndex(string(plaintext))
+}
@@ -0,0 +1,150 @@
+package encrypt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+)
+
+var (
+	key1 = []byte("0123456789abcdef0123456789abcdef")
+	key2 = []byte("fedcba9876543210")
+)
+
+type userExample struct {
+	database.Model `gorm:"embedded"`
+	Phone          string            `gorm:"column:phone;serializer:encrypted"`
+	PhoneIndex     string            `gorm:"column:phone_bidx;index;serializer:blindindex;blindindex:Phone"`
+	IDCard         *string           `gorm:"column:id_card;serializer:encrypted"`
+	Attrs          map[string]string `gorm:"column:attrs;serializer:encrypted"`
+}
+
+func TestCipher(t *testing.T) {
+	keyring, err := NewStaticKeyring("k1", map[string][]byte{"k1": key1})
+	assert.NoError(t, err)
+	c := New(keyring)
+
+	ciphertext, err := c.Encrypt("user", "phone", []byte("13800138000"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(ciphertext, "enc:v1:k1:"))
+	other, _ := c.Encrypt("user", "phone", []byte("13800138000"))
+	assert.NotEqual(t, ciphertext, other)
+
+	// the value of another column or table does not decrypt
+	_, err = c.Decrypt("user", "id_card", ciphertext)
+	assert.Error(t, err)
+	_, err = c.Decrypt("admin", "phone", ciphertext)
+	assert.Error(t, err)
+
+	// rotate the key, old values are still readable
+	assert.NoError(t, keyring.Add("k2", key2))
+	assert.NoError(t, keyring.SetCurrent("k2"))
+	plaintext, err := c.Decrypt("user", "phone", ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "13800138000", string(plaintext))
+	ciphertext, _ = c.Encrypt("user", "phone", []byte("x"))
+	assert.True(t, strings.HasPrefix(ciphertext, "enc:v1:k2:"))
+
+	_, err = c.Decrypt("user", "phone", "enc:v1:k3:AAAA")
+	assert.Error(t, err)
+	_, err = c.Decrypt("user", "phone", ciphertext[:len(ciphertext)-2])
+	assert.Error(t, err)
+
+	_, err = c.BlindIndex("x")
+	assert.ErrorIs(t, err, ErrNoBlindIndexKey)
+
+	_, err = NewStaticKeyring("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+	_, err = NewStaticKeyring("k9", map[string][]byte{"k1": key1})
+	assert.Error(t, err)
+}
+
+func TestSerializer(t *testing.T) {
+	keyring, _ := NewStaticKeyring("k1", map[string][]byte{"k1": key1})
+	Register(New(keyring, WithBlindIndexKey([]byte("index-key"))))
+
+	ctx := context.Background()
+	db := dbtest.New(t, &userExample{})
+
+	idCard := "110101199003077777"
+	user := &userExample{Phone: "13800138000", IDCard: &idCard, Attrs: map[string]string{"level": "vip"}}
+	assert.NoError(t, database.Create(ctx, db, user))
+	assert.NoError(t, database.Create(ctx, db, &userExample{Phone: "13900139000"}))
+
+	// stored encrypted
+	var raw map[string]interface{}
+	assert.NoError(t, db.Table("user_example").Where("id = ?", user.ID).Take(&raw).Error)
+	assert.True(t, IsEncrypted(raw["phone"].(string)))
+	assert.True(t, IsEncrypted(raw["id_card"].(string)))
+	assert.NotContains(t, raw["attrs"], "vip")
+
+	// read decrypted
+	got := &userExample{}
+	assert.NoError(t, database.GetByID(ctx, db, got, user.ID))
+	assert.Equal(t, "13800138000", got.Phone)
+	assert.Equal(t, idCard, *got.IDCard)
+	assert.Equal(t, "vip", got.Attrs["level"])
+
+	// equality lookup through the blind index
+	column, err := Column("phone_bidx", "13900139000")
+	assert.NoError(t, err)
+	params := &query.Params{Columns: []query.Column{column}}
+	where, args, err := params.ConvertToGormConditions()
+	assert.NoError(t, err)
+	users := []userExample{}
+	assert.NoError(t, database.List(ctx, db, &users, query.DefaultPage(0), where, args...))
+	assert.Len(t, users, 1)
+	assert.Equal(t, "13900139000", users[0].Phone)
+	assert.Nil(t, users[0].IDCard)
+
+	// legacy plaintext values are readable
+	assert.NoError(t, db.Exec("UPDATE user_example SET phone = ? WHERE id = ?", "13700137000", user.ID).Error)
+	assert.NoError(t, database.GetByID(ctx, db, got, user.ID))
+	assert.Equal(t, "13700137000", got.Phone)
+
+	// no blind index key, the lookup fails instead of matching nothing
+	Register(New(keyring))
+	defer Register(New(keyring, WithBlindIndexKey([]byte("index-key"))))
+	_, err = Column("phone_bidx", "13900139000")
+	assert.ErrorIs(t, err, ErrNoBlindIndexKey)
+}
+
+func TestCipher_updateBlindIndexes(t *testing.T) {
+	keyring, _ := NewStaticKeyring("k1", map[string][]byte{"k1": key1})
+	c := New(keyring, WithBlindIndexKey([]byte("index-key")))
+	Register(c)
+
+	ctx := context.Background()
+	db := dbtest.New(t, &userExample{})
+	assert.NoError(t, db.Use(c))
+	find := func(phone string) []userExample {
+		column, err := Column("phone_bidx", phone)
+		assert.NoError(t, err)
+		where, args, err := (&query.Params{Columns: []query.Column{column}}).ConvertToGormConditions()
+		assert.NoError(t, err)
+		users := []userExample{}
+		assert.NoError(t, database.List(ctx, db, &users, query.DefaultPage(0), where, args...))
+		return users
+	}
+
+	user := &userExample{Phone: "13800138000"}
+	assert.NoError(t, database.Create(ctx, db, user))
+
+	// the struct has no blind index, it is computed from the phone
+	assert.NoError(t, db.Model(&userExample{}).Where("id = ?", user.ID).Updates(userExample{Phone: "13900139000"}).Error)
+	assert.Empty(t, find("13800138000"))
+	assert.Len(t, find("13900139000"), 1)
+
+	// an empty phone is a zero field, it is only updated when selected, and its blind index with it
+	assert.NoError(t, db.Model(user).Select("phone").Updates(&userExample{Phone: ""}).Error)
+	assert.Empty(t, find("13900139000"))
+	got := &userExample{}
+	assert.NoError(t, database.GetByID(ctx, db, got, user.ID))
+	assert.Empty(t, got.Phone)
+	assert.Empty(t, got.PhoneIndex)
+}
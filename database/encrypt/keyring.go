@@ -0,0 +1,87 @@
+package encrypt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Keyring provides the AES keys, every key has an id that is stored with the ciphertext,
+// so values written with an old key can still be read after the current key is rotated
+type Keyring interface {
+	// Current key used to encrypt new values
+	Current() (id string, key []byte, err error)
+	// Key get the key with the given id to decrypt a value
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyring keyring holding its keys in memory
+type StaticKeyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyring create a keyring, keys must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256)
+// and current must be one of the key ids
+func NewStaticKeyring(current string, keys map[string][]byte) (*StaticKeyring, error) {
+	k := &StaticKeyring{keys: map[string][]byte{}}
+	for id, key := range keys {
+		if err := k.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.SetCurrent(current); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add a key
+func (k *StaticKeyring) Add(id string, key []byte) error {
+	if id == "" || strings.ContainsRune(id, separator) {
+		return fmt.Errorf("invalid key id '%s'", id)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("key '%s' must be 16, 24 or 32 bytes long, got %d", id, len(key))
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = key
+	return nil
+}
+
+// SetCurrent change the key used to encrypt new values
+func (k *StaticKeyring) SetCurrent(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("unknown key id '%s'", id)
+	}
+	k.current = id
+	return nil
+}
+
+// Current key used to encrypt new values
+func (k *StaticKeyring) Current() (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current, k.keys[k.current], nil
+}
+
+// Key get the key with the given id
+func (k *StaticKeyring) Key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id '%s'", id)
+	}
+	return key, nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+)
+
+// register callbacks classifying the driver errors of statements, see query.ConvertError.
+// the error of Rows is classified, the error of Row is only returned by its Scan, pass it to query.ConvertError
+func registerErrorCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	afters := []registerFunc{
+		cb.Query().After("gorm:query").Register,
+		cb.Row().After("gorm:row").Register,
+		cb.Create().After("gorm:create").Register,
+		cb.Update().After("gorm:update").Register,
+		cb.Delete().After("gorm:delete").Register,
+		cb.Raw().After("gorm:raw").Register,
+	}
+
+	for _, after := range afters {
+		err := after("library:convert_error", func(db *gorm.DB) {
+			if db.Error != nil {
+				db.Error = query.ConvertError(db.Error)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
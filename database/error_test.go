@@ -0,0 +1,43 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+)
+
+func TestCreate_convertError(t *testing.T) {
+	db := dbtest.New(t, &authorExample{})
+	// simulate the error of a mysql server
+	err := db.Callback().Create().After("gorm:create").Before("library:convert_error").Register("test:duplicate", func(db *gorm.DB) {
+		_ = db.AddError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Alice' for key 'author_example.uk_name'"})
+	})
+	assert.NoError(t, err)
+
+	err = database.Create(context.Background(), db, &authorExample{Name: "Alice"})
+	assert.ErrorIs(t, err, query.ErrDuplicateKey)
+	var dbErr *query.DBError
+	assert.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, "uk_name", dbErr.Constraint)
+}
+
+func TestRows_convertError(t *testing.T) {
+	db := dbtest.New(t, &authorExample{})
+	err := db.Callback().Row().After("gorm:row").Before("library:convert_error").Register("test:deadlock", func(db *gorm.DB) {
+		_ = db.AddError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"})
+	})
+	assert.NoError(t, err)
+
+	rows, err := db.Model(&authorExample{}).Rows()
+	if rows != nil {
+		_ = rows.Close()
+	}
+	assert.ErrorIs(t, err, query.ErrDeadlock)
+}
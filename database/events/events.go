@@ -0,0 +1,372 @@
+// Package events publishes in-process change events of models after the changes are committed.
+//
+// Register the bus as a gorm plugin once, and subscribe per model type, example:
+//
+//	bus := events.New(events.WithLogger(zlogger))
+//	if err := db.Use(bus); err != nil {
+//		return err
+//	}
+//	bus.Subscribe(&User{}, func(ctx context.Context, event events.Event) {
+//		switch e := event.(type) {
+//		case events.Created:
+//			index(e.Model.(*User))
+//		case events.Updated, events.Deleted:
+//			cache.Purge("users")
+//		}
+//	})
+//
+// Every create, update and delete run through gorm is covered, including the functions of the database package.
+// A statement outside a transaction publishes its events when it succeeds, a statement inside a transaction
+// publishes them after the transaction commits, the events of a rolled back transaction or savepoint are dropped.
+// Subscribers run synchronously in the goroutine that committed, in the order they subscribed.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event is one of Created, Updated or Deleted
+type Event interface {
+	event()
+}
+
+// Created a record was inserted
+type Created struct {
+	Table string
+	Model interface{} // pointer to the created record, one event per record of a batch
+}
+
+// Updated records were updated
+type Updated struct {
+	Table        string
+	Model        interface{}            // model of the statement, holds the primary key when a record was updated through it
+	Changed      []string               // columns assigned by the statement
+	Values       map[string]interface{} // new value of each changed column
+	RowsAffected int64
+}
+
+// Deleted records were deleted
+type Deleted struct {
+	Table        string
+	Model        interface{} // model of the statement, holds the primary key when a record was deleted through it
+	RowsAffected int64
+}
+
+func (Created) event() {}
+func (Updated) event() {}
+func (Deleted) event() {}
+
+// Handler receive the events of a model, ctx is the context of the statement that made the change
+type Handler func(ctx context.Context, event Event)
+
+// Bus dispatch the change events of models to their subscribers, it is a gorm plugin
+type Bus struct {
+	o *options
+
+	mu       sync.RWMutex
+	handlers map[reflect.Type][]Handler
+}
+
+// New create a bus, register it with db.Use
+func New(opts ...Option) *Bus {
+	o := defaultOptions()
+	o.apply(opts...)
+
+	return &Bus{o: o, handlers: map[reflect.Type][]Handler{}}
+}
+
+// Subscribe receive the events of a model, model is a struct, a pointer or a slice of it, eg: &User{}
+func (b *Bus) Subscribe(model interface{}, handler Handler) {
+	t := modelType(model)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[t] = append(b.handlers[t], handler)
+}
+
+// Name of the gorm plugin
+func (b *Bus) Name() string {
+	return "library:events"
+}
+
+// Initialize track the transactions of db and register the callbacks of the bus
+func (b *Bus) Initialize(db *gorm.DB) error {
+	pool := &connPool{ConnPool: db.ConnPool, bus: b}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+
+	const commit = "gorm:commit_or_rollback_transaction"
+	if err := db.Callback().Create().After("gorm:create").Before(commit).Register("library:events_created", b.afterCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Before(commit).Register("library:events_updated", b.afterUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Before(commit).Register("library:events_deleted", b.afterDelete); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register("library:events_savepoint", b.afterRaw)
+}
+
+func (b *Bus) afterCreate(db *gorm.DB) {
+	if !b.changed(db) {
+		return
+	}
+
+	stmt := db.Statement
+	events := []Event{}
+	switch rv := stmt.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			events = append(events, Created{Table: stmt.Table, Model: pointer(rv.Index(i))})
+		}
+	case reflect.Struct:
+		events = append(events, Created{Table: stmt.Table, Model: pointer(rv)})
+	default:
+		events = append(events, Created{Table: stmt.Table, Model: stmt.Dest})
+	}
+	b.publish(db, events...)
+}
+
+func (b *Bus) afterUpdate(db *gorm.DB) {
+	if !b.changed(db) {
+		return
+	}
+
+	stmt := db.Statement
+	event := Updated{Table: stmt.Table, Model: model(stmt), Values: map[string]interface{}{}, RowsAffected: db.RowsAffected}
+	if c, ok := stmt.Clauses["SET"]; ok {
+		if set, ok := c.Expression.(clause.Set); ok {
+			for _, assignment := range set {
+				event.Changed = append(event.Changed, assignment.Column.Name)
+				event.Values[assignment.Column.Name] = assignment.Value
+			}
+		}
+	}
+	b.publish(db, event)
+}
+
+func (b *Bus) afterDelete(db *gorm.DB) {
+	if !b.changed(db) {
+		return
+	}
+
+	stmt := db.Statement
+	b.publish(db, Deleted{Table: stmt.Table, Model: model(stmt), RowsAffected: db.RowsAffected})
+}
+
+// the savepoints of nested transactions are executed as raw statements
+func (b *Bus) afterRaw(db *gorm.DB) {
+	t := txOf(db.Statement.ConnPool)
+	if t == nil || db.Error != nil {
+		return
+	}
+
+	sql := db.Statement.SQL.String()
+	switch {
+	case strings.HasPrefix(sql, "ROLLBACK TO SAVEPOINT "):
+		t.rollbackTo(strings.TrimPrefix(sql, "ROLLBACK TO SAVEPOINT "))
+	case strings.HasPrefix(sql, "SAVEPOINT "):
+		t.savepoint(strings.TrimPrefix(sql, "SAVEPOINT "))
+	}
+}
+
+// the statement succeeded, changed rows, and its model has subscribers
+func (b *Bus) changed(db *gorm.DB) bool {
+	if db.Error != nil || db.RowsAffected == 0 || db.Statement.Schema == nil {
+		return false
+	}
+	return len(b.handlersOf(db.Statement.Schema.ModelType)) > 0
+}
+
+// dispatch the events now, or after the commit of the transaction of the statement
+func (b *Bus) publish(db *gorm.DB, events ...Event) {
+	pendings := make([]pending, 0, len(events))
+	for _, event := range events {
+		pendings = append(pendings, pending{
+			ctx:       db.Statement.Context,
+			modelType: db.Statement.Schema.ModelType,
+			table:     db.Statement.Table,
+			event:     event,
+		})
+	}
+
+	if t := txOf(db.Statement.ConnPool); t != nil {
+		t.add(pendings)
+		return
+	}
+	b.dispatch(pendings)
+}
+
+func (b *Bus) dispatch(pendings []pending) {
+	for _, p := range pendings {
+		for _, handler := range b.handlersOf(p.modelType) {
+			b.call(handler, p)
+		}
+	}
+}
+
+// a panicking subscriber does not prevent the others from receiving the event
+func (b *Bus) call(handler Handler, p pending) {
+	defer func() {
+		if e := recover(); e != nil {
+			b.o.logger.Error("events subscriber panic", zap.String("table", p.table),
+				zap.String("event", reflect.TypeOf(p.event).Name()), zap.Any("panic", e))
+		}
+	}()
+
+	handler(p.ctx, p.event)
+}
+
+func (b *Bus) handlersOf(t reflect.Type) []Handler {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.handlers[t]
+}
+
+type pending struct {
+	ctx       context.Context
+	modelType reflect.Type
+	table     string
+	event     Event
+}
+
+// connPool begin the transactions that hold the pending events
+type connPool struct {
+	gorm.ConnPool
+	bus *Bus
+}
+
+// BeginTx begin a transaction on the wrapped pool
+func (p *connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var conn gorm.ConnPool
+	var err error
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		conn, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		conn, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := conn.(gorm.Tx)
+	if !ok {
+		return conn, nil
+	}
+	return &tx{Tx: t, bus: p.bus, savepoints: map[string]int{}}, nil
+}
+
+// GetDBConn return the *sql.DB of the wrapped pool
+func (p *connPool) GetDBConn() (*sql.DB, error) {
+	if connector, ok := p.ConnPool.(gorm.GetDBConnector); ok && connector != nil {
+		return connector.GetDBConn()
+	}
+	if sqlDB, ok := p.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// tx hold the events of a transaction until it commits
+type tx struct {
+	gorm.Tx
+	bus *Bus
+
+	mu         sync.Mutex
+	pendings   []pending
+	savepoints map[string]int // number of pending events when the savepoint was set
+}
+
+// Commit the transaction and dispatch its events
+func (t *tx) Commit() error {
+	err := t.Tx.Commit()
+	pendings := t.take()
+	if err == nil {
+		t.bus.dispatch(pendings)
+	}
+	return err
+}
+
+// Rollback the transaction and drop its events
+func (t *tx) Rollback() error {
+	t.take()
+	return t.Tx.Rollback()
+}
+
+func (t *tx) add(pendings []pending) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pendings = append(t.pendings, pendings...)
+}
+
+func (t *tx) take() []pending {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pendings := t.pendings
+	t.pendings = nil
+	t.savepoints = map[string]int{}
+	return pendings
+}
+
+func (t *tx) savepoint(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.savepoints[name] = len(t.pendings)
+}
+
+func (t *tx) rollbackTo(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n, ok := t.savepoints[name]; ok && n <= len(t.pendings) {
+		t.pendings = t.pendings[:n]
+	}
+}
+
+// the transaction of a statement, gorm wraps it when prepared statements are enabled in a session
+func txOf(pool gorm.ConnPool) *tx {
+	switch p := pool.(type) {
+	case *tx:
+		return p
+	case *gorm.PreparedStmtTX:
+		t, _ := p.Tx.(*tx)
+		return t
+	}
+	return nil
+}
+
+// the model of an update or delete, the destination when no model is set
+func model(stmt *gorm.Statement) interface{} {
+	if stmt.Model != nil {
+		return stmt.Model
+	}
+	return stmt.Dest
+}
+
+// pointer to a record of the statement
+func pointer(v reflect.Value) interface{} {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return v.Interface()
+}
+
+// struct type of a model, a pointer, slice or pointer to slice of it
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"gorm.io/gorm"
+)
+
+type userExample struct {
+	ID   uint64 `gorm:"column:id;AUTO_INCREMENT;primary_key"`
+	Name string `gorm:"column:name"`
+	Age  int    `gorm:"column:age"`
+}
+
+type tagExample struct {
+	ID   uint64 `gorm:"column:id;AUTO_INCREMENT;primary_key"`
+	Name string `gorm:"column:name"`
+}
+
+func newBus(t *testing.T) (*gorm.DB, *Bus, *[]Event) {
+	db := dbtest.New(t, &userExample{}, &tagExample{})
+	bus := New()
+	assert.NoError(t, db.Use(bus))
+
+	received := &[]Event{}
+	bus.Subscribe(&userExample{}, func(ctx context.Context, event Event) {
+		*received = append(*received, event)
+	})
+	return db, bus, received
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	db, _, received := newBus(t)
+
+	user := &userExample{Name: "foo", Age: 10}
+	assert.NoError(t, database.Create(ctx, db, user))
+	assert.NoError(t, database.Create(ctx, db, &tagExample{Name: "bar"})) // not subscribed
+	assert.NoError(t, database.Update(ctx, db, &userExample{}, "age", 11, "id = ?", user.ID))
+	assert.NoError(t, database.Update(ctx, db, &userExample{}, "age", 12, "id = ?", 100)) // no row changed
+	assert.NoError(t, database.DeleteByID(ctx, db, &userExample{}, user.ID))
+
+	assert.Len(t, *received, 3)
+	created := (*received)[0].(Created)
+	assert.Equal(t, "user_example", created.Table)
+	assert.Same(t, user, created.Model)
+	updated := (*received)[1].(Updated)
+	assert.Equal(t, []string{"age"}, updated.Changed)
+	assert.Equal(t, 11, updated.Values["age"])
+	assert.Equal(t, int64(1), updated.RowsAffected)
+	deleted := (*received)[2].(Deleted)
+	assert.Equal(t, int64(1), deleted.RowsAffected)
+
+	// one event per record of a batch
+	*received = nil
+	users := []userExample{{Name: "a"}, {Name: "b"}}
+	assert.NoError(t, db.Create(&users).Error)
+	assert.Len(t, *received, 2)
+	assert.Same(t, &users[1], (*received)[1].(Created).Model)
+}
+
+func TestBus_transaction(t *testing.T) {
+	db, _, received := newBus(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		assert.NoError(t, tx.Create(&userExample{Name: "foo"}).Error)
+		assert.Empty(t, *received) // not committed yet
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, *received, 1)
+
+	// rolled back
+	*received = nil
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		assert.NoError(t, tx.Create(&userExample{Name: "bar"}).Error)
+		return errors.New("rollback")
+	})
+	tx := db.Begin()
+	assert.NoError(t, tx.Create(&userExample{Name: "bar"}).Error)
+	tx.Rollback()
+	assert.Empty(t, *received)
+
+	// the events of a rolled back savepoint are dropped
+	err = db.Transaction(func(tx *gorm.DB) error {
+		assert.NoError(t, tx.Create(&userExample{Name: "outer"}).Error)
+		_ = tx.Transaction(func(tx *gorm.DB) error {
+			assert.NoError(t, tx.Model(&userExample{}).Where("name = ?", "outer").Update("age", 1).Error)
+			return errors.New("rollback")
+		})
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Where("name = ?", "foo").Delete(&userExample{}).Error
+		})
+	})
+	assert.NoError(t, err)
+	assert.Len(t, *received, 2)
+	assert.IsType(t, Created{}, (*received)[0])
+	assert.IsType(t, Deleted{}, (*received)[1])
+}
+
+func TestBus_panic(t *testing.T) {
+	db, bus, received := newBus(t)
+	bus.Subscribe(&userExample{}, func(ctx context.Context, event Event) {
+		panic("boom")
+	})
+	bus.Subscribe(&userExample{}, func(ctx context.Context, event Event) {
+		*received = append(*received, event)
+	})
+
+	assert.NoError(t, db.Create(&userExample{Name: "foo"}).Error)
+	assert.Len(t, *received, 2)
+
+	// the pool of db still works behind the bus
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Ping())
+}
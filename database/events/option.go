@@ -0,0 +1,30 @@
+package events
+
+import "go.uber.org/zap"
+
+// Option set the bus options.
+type Option func(*options)
+
+type options struct {
+	logger *zap.Logger
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	return &options{
+		logger: zap.NewNop(),
+	}
+}
+
+// WithLogger set the logger of panicking subscribers
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// ExplainTraditional run EXPLAIN, the plan is logged as a list of rows
+	ExplainTraditional = "traditional"
+	// ExplainJSON run EXPLAIN FORMAT=JSON, the plan is logged as the json document returned by mysql
+	ExplainJSON = "json"
+
+	explainTimeout = 5 * time.Second
+	maxExplained   = 1000 // number of fingerprints remembered by the rate limiter
+
+	explainBeginKey = "library:explain_begin"
+)
+
+// plan flags
+const (
+	FlagFullTableScan  = "full table scan"
+	FlagFullIndexScan  = "full index scan"
+	FlagFilesort       = "filesort"
+	FlagTemporaryTable = "temporary table"
+	FlagNoIndex        = "no index"
+)
+
+// explainer run EXPLAIN for slow SELECT statements, at most once per interval for each fingerprint
+type explainer struct {
+	format   string
+	interval time.Duration
+	sqlDB    *sql.DB
+
+	mu        sync.Mutex
+	explained map[string]time.Time
+}
+
+func newExplainer(format string, interval time.Duration) *explainer {
+	return &explainer{
+		format:    format,
+		interval:  interval,
+		explained: map[string]time.Time{},
+	}
+}
+
+// allow report whether the statement should be explained now
+func (e *explainer) allow(fingerprint string, now time.Time) bool {
+	if e.sqlDB == nil || !isSelect(fingerprint) {
+		return false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if last, ok := e.explained[fingerprint]; ok && now.Sub(last) < e.interval {
+		return false
+	}
+	if len(e.explained) >= maxExplained {
+		for k, last := range e.explained {
+			if now.Sub(last) >= e.interval {
+				delete(e.explained, k)
+			}
+		}
+		if len(e.explained) >= maxExplained {
+			return false
+		}
+	}
+	e.explained[fingerprint] = now
+	return true
+}
+
+// explain return the plan of the statement and the flags found in it,
+// the statement is run with its placeholders and vars, as it was by the query
+func (e *explainer) explain(ctx context.Context, statement string, vars []interface{}) (interface{}, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	prefix := "EXPLAIN "
+	if e.format == ExplainJSON {
+		prefix = "EXPLAIN FORMAT=JSON "
+	}
+	rows, err := e.sqlDB.QueryContext(ctx, prefix+statement, vars...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close() //nolint
+
+	plan, err := scanRows(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if e.format == ExplainJSON {
+		if len(plan) == 0 || len(plan[0]) == 0 {
+			return nil, nil, fmt.Errorf("empty explain result")
+		}
+		for _, v := range plan[0] {
+			doc := fmt.Sprint(v)
+			return json.RawMessage(doc), jsonPlanFlags(doc), nil
+		}
+	}
+	return plan, planFlags(plan), nil
+}
+
+// register callbacks explaining the slow SELECT statements once they ran, the plan is logged as a second entry
+// after the slow query entry of the logger. the explainer runs its statements on the connection pool of the opened database
+func registerExplainCallbacks(db *gorm.DB, sqlDB *sql.DB) error {
+	l, ok := db.Config.Logger.(*logger)
+	if !ok || l.explainer == nil {
+		return nil
+	}
+	l.explainer.sqlDB = sqlDB
+
+	cb := db.Callback()
+	stages := []struct {
+		before, after registerFunc
+	}{
+		{cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+	}
+	for _, s := range stages {
+		err := s.before("library:explain_begin", func(db *gorm.DB) {
+			db.InstanceSet(explainBeginKey, time.Now())
+		})
+		if err != nil {
+			return err
+		}
+		if err = s.after("library:explain", l.explainSlow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explain the statement in the background when it was slow
+func (l *logger) explainSlow(db *gorm.DB) {
+	v, ok := db.InstanceGet(explainBeginKey)
+	if !ok || db.Error != nil || time.Since(v.(time.Time)) <= l.slowThreshold {
+		return
+	}
+	statement := db.Statement.SQL.String()
+	fingerprint := Fingerprint(statement)
+	if !l.explainer.allow(fingerprint, time.Now()) {
+		return
+	}
+
+	vars := append([]interface{}{}, db.Statement.Vars...)
+	go func() {
+		plan, flags, err := l.explainer.explain(context.Background(), statement, vars)
+		if err != nil {
+			l.logger.Warn("slow query plan", zap.String("fingerprint", fingerprint), zap.String("explain_error", err.Error()))
+			return
+		}
+		l.logger.Warn("slow query plan", zap.String("fingerprint", fingerprint), zap.Any("plan", plan), zap.Strings("flags", flags))
+	}()
+}
+
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// flags of a traditional plan, one row per table
+func planFlags(plan []map[string]interface{}) []string {
+	flags := []string{}
+	for _, row := range plan {
+		if row["table"] == nil {
+			continue
+		}
+		switch fmt.Sprint(row["type"]) {
+		case "ALL":
+			flags = appendFlag(flags, FlagFullTableScan)
+		case "index":
+			flags = appendFlag(flags, FlagFullIndexScan)
+		}
+		if row["key"] == nil && row["type"] != nil {
+			flags = appendFlag(flags, FlagNoIndex)
+		}
+		extra := fmt.Sprint(row["Extra"])
+		if strings.Contains(extra, "Using filesort") {
+			flags = appendFlag(flags, FlagFilesort)
+		}
+		if strings.Contains(extra, "Using temporary") {
+			flags = appendFlag(flags, FlagTemporaryTable)
+		}
+	}
+	return flags
+}
+
+// flags of a FORMAT=JSON plan
+func jsonPlanFlags(doc string) []string {
+	doc = strings.Join(strings.Fields(doc), "")
+	flags := []string{}
+	if strings.Contains(doc, `"access_type":"ALL"`) {
+		flags = appendFlag(flags, FlagFullTableScan)
+	}
+	if strings.Contains(doc, `"access_type":"index"`) {
+		flags = appendFlag(flags, FlagFullIndexScan)
+	}
+	if strings.Contains(doc, `"using_filesort":true`) {
+		flags = appendFlag(flags, FlagFilesort)
+	}
+	if strings.Contains(doc, `"using_temporary_table":true`) {
+		flags = appendFlag(flags, FlagTemporaryTable)
+	}
+	return flags
+}
+
+func appendFlag(flags []string, flag string) []string {
+	for _, f := range flags {
+		if f == flag {
+			return flags
+		}
+	}
+	return append(flags, flag)
+}
+
+func isSelect(fingerprint string) bool {
+	return strings.HasPrefix(strings.TrimLeft(fingerprint, "( "), "select ")
+}
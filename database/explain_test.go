@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+)
+
+func TestExplainer_allow(t *testing.T) {
+	e := newExplainer(ExplainTraditional, time.Minute)
+	now := time.Now()
+	assert.False(t, e.allow("select * from t", now)) // not opened
+
+	db, err := OpenDialector(sqlite.Open(":memory:"))
+	assert.NoError(t, err)
+	e.sqlDB, _ = db.DB()
+
+	assert.True(t, e.allow("select * from t", now))
+	assert.False(t, e.allow("select * from t", now.Add(time.Second)))
+	assert.True(t, e.allow("select * from t", now.Add(time.Minute)))
+	assert.False(t, e.allow("update t set a = ?", now))
+}
+
+func TestPlanFlags(t *testing.T) {
+	plan := []map[string]interface{}{
+		{"table": "user", "type": "ALL", "key": nil, "Extra": "Using where; Using temporary; Using filesort"},
+		{"table": "order", "type": "ref", "key": "idx_user_id", "Extra": nil},
+	}
+	assert.Equal(t, []string{FlagFullTableScan, FlagNoIndex, FlagFilesort, FlagTemporaryTable}, planFlags(plan))
+
+	doc := `{"query_block": {"ordering_operation": {"using_filesort": true, "table": {"access_type": "ALL"}}}}`
+	assert.Equal(t, []string{FlagFullTableScan, FlagFilesort}, jsonPlanFlags(doc))
+}
+
+func TestLogger_slowQueryExplain(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	db, err := OpenDialector(sqlite.Open(":memory:"),
+		WithMaxOpenConns(1),
+		WithLog(true, zap.New(core), time.Nanosecond),
+		WithSlowQueryExplain(ExplainTraditional, time.Minute),
+	)
+	assert.NoError(t, err)
+
+	var n int
+	err = db.Raw("SELECT 1 WHERE 2 > ?", 1).Scan(&n).Error
+	assert.NoError(t, err)
+
+	// the slow query is logged right away, the plan follows
+	assert.Equal(t, 1, logs.FilterMessage("slow query").FilterField(zap.String("fingerprint", "select ? where ? > ?")).Len())
+	assert.Eventually(t, func() bool {
+		return logs.FilterMessage("slow query plan").FilterField(zap.String("fingerprint", "select ? where ? > ?")).Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	entry := logs.FilterMessage("slow query plan").All()[0]
+	assert.Contains(t, entry.ContextMap(), "plan")
+	assert.NotContains(t, entry.ContextMap(), "explain_error")
+}
+
+func TestLogger_slowQueryExplain_vars(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	db, err := OpenDialector(sqlite.Open(":memory:"),
+		WithMaxOpenConns(1),
+		WithLog(true, zap.New(core), time.Nanosecond),
+		WithSlowQueryExplain(ExplainTraditional, time.Minute),
+		WithRedactColumns("name"),
+	)
+	assert.NoError(t, err)
+
+	// the plan is of the statement with its vars, not of the logged sql with the redacted or escaped values
+	var n int
+	err = db.Raw("SELECT count(*) FROM (SELECT 'x' AS name) t WHERE name = ?", `a\' OR 1=1 --`).Scan(&n).Error
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return logs.FilterMessage("slow query plan").Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	entry := logs.FilterMessage("slow query plan").All()[0]
+	assert.Contains(t, entry.ContextMap(), "plan")
+	assert.NotContains(t, entry.ContextMap(), "explain_error")
+}
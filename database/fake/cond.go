@@ -0,0 +1,676 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// the conditions understood by the fake store are the ones built by the database package and by query.Cond
+// and query.Params in the sqlite dialect, they are SQL text so they are parsed here rather than read from the params:
+//
+//	condition  = or
+//	or         = and { OR and }
+//	and        = not { AND not }
+//	not        = NOT not | ( or ) | predicate
+//	predicate  = operand ( = | <> | != | > | >= | < | <= ) operand
+//	           | operand [NOT] IN ( ? | ( operand|? { , operand|? } ) )   a ? bound to a slice is its elements
+//	           | operand IS [NOT] NULL
+//	           | operand [NOT] LIKE operand [ESCAPE operand]               % and _ wildcards, case insensitive
+//	operand    = column | ? | 'text' | number | NULL | TRUE | FALSE | ( operand )
+//	           | json_extract(column, '$.key[0]...')
+//	column     = name | `name` | "name", a table qualifier is ignored, example: `user`.`name`
+//
+// comparisons with NULL are unknown, the keywords are case insensitive, anything else is an error,
+// notably functions other than json_extract, arithmetic, BETWEEN, subqueries and the EXISTS of the contains expression
+
+// truth value of a condition, comparisons with NULL are unknown as in SQL
+type truth int
+
+const (
+	unknown truth = iota
+	isFalse
+	isTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+type condition interface {
+	eval(row reflect.Value) (truth, error)
+}
+
+type operand interface {
+	value(row reflect.Value) (interface{}, error)
+}
+
+type andCond struct{ left, right condition }
+
+func (c andCond) eval(row reflect.Value) (truth, error) {
+	l, err := c.left.eval(row)
+	if err != nil || l == isFalse {
+		return l, err
+	}
+	r, err := c.right.eval(row)
+	if err != nil || r == isFalse {
+		return r, err
+	}
+	if l == unknown || r == unknown {
+		return unknown, nil
+	}
+	return isTrue, nil
+}
+
+type orCond struct{ left, right condition }
+
+func (c orCond) eval(row reflect.Value) (truth, error) {
+	l, err := c.left.eval(row)
+	if err != nil || l == isTrue {
+		return l, err
+	}
+	r, err := c.right.eval(row)
+	if err != nil || r == isTrue {
+		return r, err
+	}
+	if l == unknown || r == unknown {
+		return unknown, nil
+	}
+	return isFalse, nil
+}
+
+type notCond struct{ cond condition }
+
+func (c notCond) eval(row reflect.Value) (truth, error) {
+	t, err := c.cond.eval(row)
+	switch t {
+	case isTrue:
+		return isFalse, err
+	case isFalse:
+		return isTrue, err
+	}
+	return unknown, err
+}
+
+type compareCond struct {
+	op          string
+	left, right operand
+}
+
+func (c compareCond) eval(row reflect.Value) (truth, error) {
+	l, err := c.left.value(row)
+	if err != nil {
+		return unknown, err
+	}
+	r, err := c.right.value(row)
+	if err != nil {
+		return unknown, err
+	}
+	if l == nil || r == nil {
+		return unknown, nil
+	}
+
+	n, err := compare(l, r)
+	if err != nil {
+		return unknown, err
+	}
+	switch c.op {
+	case "=":
+		return truthOf(n == 0), nil
+	case "<>", "!=":
+		return truthOf(n != 0), nil
+	case ">":
+		return truthOf(n > 0), nil
+	case ">=":
+		return truthOf(n >= 0), nil
+	case "<":
+		return truthOf(n < 0), nil
+	case "<=":
+		return truthOf(n <= 0), nil
+	}
+	return unknown, fmt.Errorf("unsupported operator '%s'", c.op)
+}
+
+type inCond struct {
+	left   operand
+	values []operand
+}
+
+func (c inCond) eval(row reflect.Value) (truth, error) {
+	l, err := c.left.value(row)
+	if err != nil || l == nil {
+		return unknown, err
+	}
+	result := isFalse
+	for _, v := range c.values {
+		r, err := v.value(row)
+		if err != nil {
+			return unknown, err
+		}
+		if r == nil {
+			result = unknown
+			continue
+		}
+		n, err := compare(l, r)
+		if err != nil {
+			return unknown, err
+		}
+		if n == 0 {
+			return isTrue, nil
+		}
+	}
+	return result, nil
+}
+
+type nullCond struct{ operand operand }
+
+func (c nullCond) eval(row reflect.Value) (truth, error) {
+	v, err := c.operand.value(row)
+	return truthOf(v == nil), err
+}
+
+type likeCond struct {
+	left, pattern operand
+	escape        operand // nil without ESCAPE
+}
+
+func (c likeCond) eval(row reflect.Value) (truth, error) {
+	l, err := c.left.value(row)
+	if err != nil {
+		return unknown, err
+	}
+	p, err := c.pattern.value(row)
+	if err != nil {
+		return unknown, err
+	}
+	if l == nil || p == nil {
+		return unknown, nil
+	}
+	escape := rune(-1)
+	if c.escape != nil {
+		e, err := c.escape.value(row)
+		if err != nil {
+			return unknown, err
+		}
+		s := []rune(fmt.Sprint(e))
+		if len(s) != 1 {
+			return unknown, fmt.Errorf("ESCAPE expression must be a single character")
+		}
+		escape = s[0]
+	}
+
+	re := strings.Builder{}
+	re.WriteString("(?is)^")
+	escaped := false
+	for _, r := range fmt.Sprint(p) {
+		switch {
+		case escaped:
+			escaped = false
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		case r == escape:
+			escaped = true
+		case r == '%':
+			re.WriteString(".*")
+		case r == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	matched, err := regexp.MatchString(re.String(), fmt.Sprint(l))
+	return truthOf(matched), err
+}
+
+type literal struct{ v interface{} }
+
+func (o literal) value(reflect.Value) (interface{}, error) {
+	return normalize(o.v), nil
+}
+
+type columnRef struct {
+	field *schema.Field
+}
+
+func (o columnRef) value(row reflect.Value) (interface{}, error) {
+	v, _ := o.field.ValueOf(context.Background(), row)
+	return normalize(v), nil
+}
+
+// json_extract(column, '$.path')
+type jsonRef struct {
+	column columnRef
+	path   []interface{} // string keys and int indexes
+}
+
+func (o jsonRef) value(row reflect.Value) (interface{}, error) {
+	v, err := o.column.value(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("column %s is not json", o.column.field.DBName)
+	}
+	var doc interface{}
+	if err = json.Unmarshal([]byte(s), &doc); err != nil {
+		return nil, nil // malformed json is NULL
+	}
+	for _, segment := range o.path {
+		switch key := segment.(type) {
+		case string:
+			m, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			doc = m[key]
+		case int:
+			a, ok := doc.([]interface{})
+			if !ok || key >= len(a) {
+				return nil, nil
+			}
+			doc = a[key]
+		}
+	}
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(doc)
+		return string(b), nil
+	}
+	return normalize(doc), nil
+}
+
+// parseCondition parse the condition of a statement on the rows of sch, the ? placeholders are bound to args
+func parseCondition(sch *schema.Schema, sql string, args []interface{}) (condition, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{sch: sch, tokens: tokens, args: args}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().text != "" {
+		return nil, fmt.Errorf("unexpected '%s' in condition '%s'", p.peek().text, sql)
+	}
+	if p.arg != len(args) {
+		return nil, fmt.Errorf("condition '%s' has %d placeholders for %d arguments", sql, p.arg, len(args))
+	}
+	return cond, nil
+}
+
+type condToken struct {
+	text   string
+	quoted bool // a quoted string literal
+	ident  bool // a quoted identifier
+}
+
+type condParser struct {
+	sch    *schema.Schema
+	tokens []condToken
+	pos    int
+	args   []interface{}
+	arg    int
+}
+
+func (p *condParser) peek() condToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return condToken{}
+}
+
+func (p *condParser) keyword(words ...string) bool {
+	t := p.peek()
+	if t.quoted || t.ident || !strings.EqualFold(t.text, words[0]) {
+		return false
+	}
+	for i, word := range words[1:] {
+		next := condToken{}
+		if p.pos+i+1 < len(p.tokens) {
+			next = p.tokens[p.pos+i+1]
+		}
+		if next.quoted || next.ident || !strings.EqualFold(next.text, word) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *condParser) expect(text string) error {
+	if t := p.peek(); t.quoted || t.text != text {
+		return fmt.Errorf("expected '%s', found '%s'", text, t.text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *condParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseNot() (condition, error) {
+	if p.keyword("not") {
+		cond, err := p.parseNot()
+		return notCond{cond}, err
+	}
+	if t := p.peek(); t.text == "(" && !t.quoted {
+		// a group, or an operand in parentheses such as (?) of a comparison
+		start, arg := p.pos, p.arg
+		p.pos++
+		cond, err := p.parseOr()
+		if err == nil && p.expect(")") == nil {
+			return cond, nil
+		}
+		p.pos, p.arg = start, arg
+	}
+	return p.parsePredicate()
+}
+
+func (p *condParser) parsePredicate() (condition, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.keyword("is", "not", "null"):
+		return notCond{nullCond{left}}, nil
+	case p.keyword("is", "null"):
+		return nullCond{left}, nil
+	case p.keyword("not", "in"):
+		cond, err := p.parseIn(left)
+		return notCond{cond}, err
+	case p.keyword("in"):
+		return p.parseIn(left)
+	case p.keyword("not", "like"):
+		cond, err := p.parseLike(left)
+		return notCond{cond}, err
+	case p.keyword("like"):
+		return p.parseLike(left)
+	}
+
+	op := p.peek()
+	switch op.text {
+	case "=", "<>", "!=", ">", ">=", "<", "<=":
+		if op.quoted {
+			break
+		}
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareCond{op: op.text, left: left, right: right}, nil
+	}
+	return nil, fmt.Errorf("unsupported condition at '%s'", op.text)
+}
+
+// LIKE pattern, or LIKE pattern ESCAPE character
+func (p *condParser) parseLike(left operand) (condition, error) {
+	pattern, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	cond := likeCond{left: left, pattern: pattern}
+	if p.keyword("escape") {
+		cond.escape, err = p.parseOperand()
+	}
+	return cond, err
+}
+
+// IN ?, IN (?) with a slice argument, or IN (a, b)
+func (p *condParser) parseIn(left operand) (condition, error) {
+	if t := p.peek(); t.text == "?" && !t.quoted {
+		return inCond{left: left, values: p.bindList()}, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	values := []operand{}
+	for {
+		if t := p.peek(); t.text == "?" && !t.quoted {
+			values = append(values, p.bindList()...)
+		} else {
+			v, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		if t := p.peek(); t.text == "," && !t.quoted {
+			p.pos++
+			continue
+		}
+		return inCond{left: left, values: values}, p.expect(")")
+	}
+}
+
+// the elements of the slice bound to the next ?, or the argument itself when it is not a slice
+func (p *condParser) bindList() []operand {
+	p.pos++
+	values := []operand{}
+	if p.arg >= len(p.args) {
+		p.arg++
+		return values
+	}
+	arg := p.args[p.arg]
+	p.arg++
+
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, literal{rv.Index(i).Interface()})
+		}
+		return values
+	}
+	return append(values, literal{arg})
+}
+
+func (p *condParser) parseOperand() (operand, error) {
+	t := p.peek()
+	if t.text == "" && !t.quoted {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+
+	switch {
+	case t.quoted:
+		return literal{t.text}, nil
+	case t.ident:
+		return p.column(t.text)
+	case t.text == "?":
+		if p.arg >= len(p.args) {
+			p.arg++
+			return literal{nil}, nil
+		}
+		arg := p.args[p.arg]
+		p.arg++
+		return literal{arg}, nil
+	case t.text == "(":
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return o, p.expect(")")
+	case strings.EqualFold(t.text, "null"):
+		return literal{nil}, nil
+	case strings.EqualFold(t.text, "true"), strings.EqualFold(t.text, "false"):
+		return literal{strings.EqualFold(t.text, "true")}, nil
+	case strings.EqualFold(t.text, "json_extract"):
+		return p.parseJSONExtract()
+	case t.text[0] == '-' || t.text[0] == '.' || (t.text[0] >= '0' && t.text[0] <= '9'):
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", t.text)
+		}
+		return literal{f}, nil
+	}
+	return p.column(t.text)
+}
+
+// json_extract(column, '$.key[0]')
+func (p *condParser) parseJSONExtract() (operand, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	column, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	ref, ok := column.(columnRef)
+	if !ok {
+		return nil, fmt.Errorf("json_extract of a column is expected")
+	}
+	if err = p.expect(","); err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if !t.quoted || !strings.HasPrefix(t.text, "$") {
+		return nil, fmt.Errorf("invalid json path '%s'", t.text)
+	}
+	p.pos++
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	o := jsonRef{column: ref}
+	path := t.text[1:]
+	for path != "" {
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			o.path = append(o.path, strings.Trim(path[1:end], `"`))
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			index, err := strconv.Atoi(path[1:max(end, 1)])
+			if end < 0 || err != nil {
+				return nil, fmt.Errorf("invalid json path '%s'", t.text)
+			}
+			o.path = append(o.path, index)
+			path = path[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path '%s'", t.text)
+		}
+	}
+	return o, nil
+}
+
+// a column of the model, the table qualifier is ignored, example: user.name
+func (p *condParser) column(name string) (operand, error) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	field := p.sch.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return nil, fmt.Errorf("no such column: %s", name)
+	}
+	return columnRef{field: field}, nil
+}
+
+func tokenize(sql string) ([]condToken, error) {
+	tokens := []condToken{}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '\'' || c == '`' || c == '"':
+			b := strings.Builder{}
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c { // doubled quote
+						b.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(sql[j])
+			}
+			if j >= len(sql) {
+				return nil, fmt.Errorf("unterminated quote in condition '%s'", sql)
+			}
+			i = j
+			name := b.String()
+			// a qualified name, example: `user`.`name`
+			for c != '\'' && i+2 < len(sql) && sql[i+1] == '.' && sql[i+2] == c {
+				k := strings.IndexByte(sql[i+3:], c)
+				if k < 0 {
+					return nil, fmt.Errorf("unterminated quote in condition '%s'", sql)
+				}
+				name = sql[i+3 : i+3+k]
+				i += k + 3
+			}
+			tokens = append(tokens, condToken{text: name, quoted: c == '\'', ident: c != '\''})
+		case strings.ContainsRune("(),?", rune(c)):
+			tokens = append(tokens, condToken{text: string(c)})
+		case strings.ContainsRune("=<>!", rune(c)):
+			op := string(c)
+			if i+1 < len(sql) && (sql[i+1] == '=' || (c == '<' && sql[i+1] == '>')) {
+				op += string(sql[i+1])
+				i++
+			}
+			tokens = append(tokens, condToken{text: op})
+		default:
+			j := i
+			for j < len(sql) && !strings.ContainsRune(" \t\n\r(),?=<>!'`\"", rune(sql[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character '%c' in condition '%s'", c, sql)
+			}
+			tokens = append(tokens, condToken{text: sql[i:j]})
+			i = j - 1
+		}
+	}
+	return tokens, nil
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+package fake
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+)
+
+func TestParseCondition(t *testing.T) {
+	sch, err := schema.Parse(&userExample{}, &sync.Map{}, namer)
+	require.NoError(t, err)
+	email := "bob@example.com"
+	row := reflect.ValueOf(&userExample{Name: "Bob", Age: 20, Email: &email, Attrs: `{"color":"red","sizes":[40,42]}`}).Elem()
+
+	tests := []struct {
+		sql  string
+		args []interface{}
+		want truth
+	}{
+		{"`user_example`.`name` = ? AND (age > ? OR age < ?)", []interface{}{"Bob", 30, 25}, isTrue},
+		{"NOT (name <> 'Bob') AND 1 = 1", nil, isTrue},
+		{"name like ?", []interface{}{"b_b"}, isTrue},
+		{"name NOT LIKE ?", []interface{}{"%o%"}, isFalse},
+		{`name LIKE ? ESCAPE '\'`, []interface{}{`b\_b`}, isFalse},
+		{`name LIKE ? ESCAPE '\'`, []interface{}{`%o%`}, isTrue},
+		{`json_extract(attrs, '$.color') LIKE ? ESCAPE '!'`, []interface{}{`re!%`}, isFalse},
+		{"deleted_at IS NULL AND email IS NOT NULL", nil, isTrue},
+		{"deleted_at = ?", []interface{}{nil}, unknown},
+		{"age IN (?)", []interface{}{[]int{1, 20}}, isTrue},
+		{"age NOT IN (1, ?)", []interface{}{nil}, unknown},
+		{"created_at < ?", []interface{}{"2026-01-01"}, isTrue},
+		{"json_extract(attrs, '$.color') = ? AND json_extract(attrs, '$.sizes[1]') > ?", []interface{}{"red", 41}, isTrue},
+		{"json_extract(attrs, '$.missing') IS NULL", nil, isTrue},
+	}
+	for _, tt := range tests {
+		cond, err := parseCondition(sch, tt.sql, tt.args)
+		require.NoError(t, err, tt.sql)
+		got, err := cond.eval(row)
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+
+	_, err = parseCondition(sch, "age BETWEEN ? AND ?", []interface{}{1, 2})
+	assert.EqualError(t, err, "unsupported condition at 'BETWEEN'")
+	_, err = parseCondition(sch, "name = ? AND", []interface{}{"bob"})
+	assert.EqualError(t, err, "unexpected end of condition")
+}
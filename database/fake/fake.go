@@ -0,0 +1,676 @@
+// Package fake provides an in-memory database.Store for the unit tests of services, without SQL or a driver.
+//
+// The records are kept in Go slices and the conditions built by the database and query packages are evaluated
+// in Go, so a service depending on database.Store behaves the same with the fake as with a database, example:
+//
+//	store := fake.New()
+//	svc := NewUserService(store) // database.NewStore(db) in production
+//	err := svc.Register(ctx, "alice")
+//	err = store.Get(ctx, &user, "name = ?", "alice")
+//
+// The store assigns auto increment ids, fills the created_at, updated_at and deleted_at columns, soft deletes
+// the models with a gorm.DeletedAt field, fails with query.ErrDuplicateKey on a duplicate primary or unique key,
+// with query.ErrNotFound when Get finds no record and with gorm.ErrMissingWhereClause on an unconditional
+// update or delete, the non-zero primary key of the model being a condition as with gorm. Params are validated as by the database package, included relations are not loaded
+// and the relevance score of the match columns is neither computed nor sortable.
+//
+// The conditions are the comparisons of columns with placeholders or literals, [NOT] IN, IS [NOT] NULL, [NOT] LIKE [ESCAPE],
+// json_extract of the sqlite dialect, combined with AND, OR, NOT and parentheses, or a map of column values,
+// or a query.Cond. Other SQL fails with an error instead of being ignored.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var _ database.Store = (*Store)(nil)
+
+// Store an in-memory database.Store, safe for concurrent use
+type Store struct {
+	o *options
+
+	mu      sync.Mutex
+	schemas sync.Map
+	tables  map[string]*table
+}
+
+type table struct {
+	schema    *schema.Schema
+	rows      []reflect.Value // addressable structs
+	seq       int64           // last auto increment id
+	deletedAt *schema.Field   // nil when the model is not soft deleted
+	uniques   [][]*schema.Field
+}
+
+// New create an empty store
+func New(opts ...Option) *Store {
+	o := defaultOptions()
+	o.apply(opts...)
+	return &Store{o: o, tables: map[string]*table{}}
+}
+
+// the naming of the database package
+var namer = schema.NamingStrategy{SingularTable: true}
+
+func (s *Store) table(model interface{}) (*table, error) {
+	sch, err := schema.Parse(model, &s.schemas, namer)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := s.tables[sch.Table]; ok {
+		return t, nil
+	}
+
+	t := &table{schema: sch}
+	indexes := sch.ParseIndexes() // marks the fields of single column unique indexes as unique
+	for _, field := range sch.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			t.deletedAt = field
+		}
+		if field.Unique && !field.PrimaryKey {
+			t.uniques = append(t.uniques, []*schema.Field{field})
+		}
+	}
+	t.uniques = append(t.uniques, sch.PrimaryFields)
+	for _, index := range indexes {
+		if index.Class == "UNIQUE" && len(index.Fields) > 1 {
+			fields := []*schema.Field{}
+			for _, option := range index.Fields {
+				fields = append(fields, option.Field)
+			}
+			t.uniques = append(t.uniques, fields)
+		}
+	}
+	s.tables[sch.Table] = t
+	return t, nil
+}
+
+// the condition of query and args, nil when there is none
+func (t *table) where(q interface{}, args []interface{}) (condition, error) {
+	switch v := q.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return parseCondition(t.schema, v, args)
+	case interface {
+		ToGormConditions() (string, []interface{}, error)
+	}: // query.Cond
+		sql, vars, err := v.ToGormConditions()
+		if err != nil || sql == "" {
+			return nil, err
+		}
+		return parseCondition(t.schema, sql, vars)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var cond condition
+		for _, key := range keys {
+			ref, err := (&condParser{sch: t.schema}).column(key)
+			if err != nil {
+				return nil, err
+			}
+			var c condition = compareCond{op: "=", left: ref, right: literal{v[key]}}
+			if v[key] == nil {
+				c = nullCond{ref}
+			}
+			if cond == nil {
+				cond = c
+			} else {
+				cond = andCond{cond, c}
+			}
+		}
+		return cond, nil
+	}
+	return nil, fmt.Errorf("query of type %T is not supported by the fake store", q)
+}
+
+// add the non-zero primary key of the model to cond, as gorm does for Updates and Delete
+func (t *table) withPrimaryKey(ctx context.Context, model interface{}, cond condition) (condition, error) {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return cond, nil
+	}
+	for _, field := range t.schema.PrimaryFields {
+		value, isZero := field.ValueOf(ctx, v)
+		if isZero {
+			continue
+		}
+		ref, err := (&condParser{sch: t.schema}).column(field.DBName)
+		if err != nil {
+			return nil, err
+		}
+		var c condition = compareCond{op: "=", left: ref, right: literal{value}}
+		if cond != nil {
+			c = andCond{cond, c}
+		}
+		cond = c
+	}
+	return cond, nil
+}
+
+// the rows that are not soft deleted and match cond
+func (t *table) find(cond condition) ([]reflect.Value, error) {
+	rows := []reflect.Value{}
+	for _, row := range t.rows {
+		if t.deletedAt != nil {
+			if v, _ := t.deletedAt.ValueOf(context.Background(), row); normalize(v) != nil {
+				continue
+			}
+		}
+		if cond != nil {
+			ok, err := cond.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			if ok != isTrue {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// the table of the model and its rows matching the query
+func (s *Store) find(model interface{}, q interface{}, args []interface{}) (*table, []reflect.Value, error) {
+	t, err := s.table(model)
+	if err != nil {
+		return nil, nil, err
+	}
+	cond, err := t.where(q, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := t.find(cond)
+	return t, rows, err
+}
+
+// Create a new record, or the records of a pointer to a slice
+func (s *Store) Create(ctx context.Context, table interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(table)
+	if err != nil {
+		return err
+	}
+	records := []reflect.Value{}
+	rv := reflect.Indirect(reflect.ValueOf(table))
+	if rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			records = append(records, reflect.Indirect(rv.Index(i)))
+		}
+	} else {
+		records = append(records, rv)
+	}
+
+	now := s.o.now()
+	seq := t.seq
+	rows := append([]reflect.Value{}, t.rows...)
+	for _, record := range records {
+		if !record.CanAddr() {
+			return fmt.Errorf("create a %s by pointer", record.Type())
+		}
+		if pk := t.schema.PrioritizedPrimaryField; pk != nil && isInteger(pk) {
+			v, zero := pk.ValueOf(ctx, record)
+			if zero {
+				seq++
+				if err = pk.Set(ctx, record, seq); err != nil {
+					return err
+				}
+			} else if n, _ := toFloat(normalize(v)); int64(n) > seq {
+				seq = int64(n)
+			}
+		}
+		for _, field := range t.schema.Fields {
+			if _, zero := field.ValueOf(ctx, record); zero && (field.AutoCreateTime > 0 || field.AutoUpdateTime > 0) {
+				if err = field.Set(ctx, record, timestamp(field, now)); err != nil {
+					return err
+				}
+			}
+		}
+
+		row := reflect.New(record.Type()).Elem()
+		row.Set(record)
+		if err = t.checkUnique(rows, row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	t.rows, t.seq = rows, seq
+	return nil
+}
+
+// query.ErrDuplicateKey when the row has the same primary or unique key as another row, soft deleted rows included
+func (t *table) checkUnique(rows []reflect.Value, row reflect.Value) error {
+	for _, fields := range t.uniques {
+		if len(fields) == 0 {
+			continue
+		}
+		for _, other := range rows {
+			if other.Addr().Pointer() == row.Addr().Pointer() {
+				continue
+			}
+			same := true
+			for _, field := range fields {
+				a, _ := field.ValueOf(context.Background(), row)
+				b, _ := field.ValueOf(context.Background(), other)
+				a, b = normalize(a), normalize(b)
+				if a == nil || b == nil {
+					same = false
+					break
+				}
+				if n, err := compare(a, b); err != nil || n != 0 {
+					same = false
+					break
+				}
+			}
+			if same {
+				return fmt.Errorf("%w: %s", query.ErrDuplicateKey, t.schema.Table)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete the records matching the condition, the models with a gorm.DeletedAt field are soft deleted
+func (s *Store) Delete(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(table)
+	if err != nil {
+		return err
+	}
+	cond, err := t.where(query, args)
+	if err != nil {
+		return err
+	}
+	if cond, err = t.withPrimaryKey(ctx, table, cond); err != nil {
+		return err
+	}
+	if cond == nil {
+		return gorm.ErrMissingWhereClause
+	}
+	rows, err := t.find(cond)
+	if err != nil {
+		return err
+	}
+
+	if t.deletedAt != nil {
+		now := s.o.now()
+		for _, row := range rows {
+			if err = t.deletedAt.Set(ctx, row, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	deleted := map[uintptr]bool{}
+	for _, row := range rows {
+		deleted[row.Addr().Pointer()] = true
+	}
+	kept := []reflect.Value{}
+	for _, row := range t.rows {
+		if !deleted[row.Addr().Pointer()] {
+			kept = append(kept, row)
+		}
+	}
+	t.rows = kept
+	return nil
+}
+
+// DeleteByID delete record by id
+func (s *Store) DeleteByID(ctx context.Context, table interface{}, id interface{}) error {
+	return s.Delete(ctx, table, "id = ?", id)
+}
+
+// Update a column of the records matching the condition
+func (s *Store) Update(ctx context.Context, table interface{}, column string, value interface{}, query interface{}, args ...interface{}) error {
+	return s.Updates(ctx, table, database.KV{column: value}, query, args...)
+}
+
+// Updates the columns of the records matching the condition, the updated_at column is set,
+// the model is updated too as by gorm
+func (s *Store) Updates(ctx context.Context, table interface{}, update database.KV, query interface{}, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(table)
+	if err != nil {
+		return err
+	}
+	cond, err := t.where(query, args)
+	if err != nil {
+		return err
+	}
+	if cond, err = t.withPrimaryKey(ctx, table, cond); err != nil {
+		return err
+	}
+	if cond == nil {
+		return gorm.ErrMissingWhereClause
+	}
+
+	values := map[*schema.Field]interface{}{}
+	for column, value := range update {
+		field := t.schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return fmt.Errorf("no such column: %s", column)
+		}
+		values[field] = value
+	}
+	now := s.o.now()
+	for _, field := range t.schema.Fields {
+		if _, ok := values[field]; !ok && field.AutoUpdateTime > 0 {
+			values[field] = timestamp(field, now)
+		}
+	}
+
+	rows, err := t.find(cond)
+	if err != nil {
+		return err
+	}
+	// an UPDATE is atomic, all the rows are restored when one of them fails
+	olds := make([]reflect.Value, len(rows))
+	for i, row := range rows {
+		olds[i] = reflect.New(row.Type()).Elem()
+		olds[i].Set(row)
+	}
+	restore := func() {
+		for i, row := range rows {
+			row.Set(olds[i])
+		}
+	}
+	for _, row := range rows {
+		for field, value := range values {
+			if err = field.Set(ctx, row, value); err != nil {
+				restore()
+				return err
+			}
+		}
+	}
+	for _, row := range rows {
+		if err = t.checkUnique(t.rows, row); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	if model := reflect.Indirect(reflect.ValueOf(table)); model.Kind() == reflect.Struct && model.CanAddr() {
+		for field, value := range values {
+			_ = field.Set(ctx, model, value)
+		}
+	}
+	return nil
+}
+
+// Get the first record matching the condition by primary key, query.ErrNotFound when there is none
+func (s *Store) Get(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, rows, err := s.find(table, query, args)
+	if err != nil {
+		return err
+	}
+	return t.first(table, rows, nil)
+}
+
+// GetByID get record by id
+func (s *Store) GetByID(ctx context.Context, table interface{}, id interface{}) error {
+	return s.Get(ctx, table, "id = ?", id)
+}
+
+func (t *table) first(table interface{}, rows []reflect.Value, columns []string) error {
+	if len(rows) == 0 {
+		return query.ErrNotFound
+	}
+	order := []string{}
+	for _, column := range t.schema.PrimaryFieldDBNames {
+		order = append(order, column+" ASC")
+	}
+	if err := t.sort(rows, strings.Join(order, ",")); err != nil {
+		return err
+	}
+	reflect.ValueOf(table).Elem().Set(t.copy(rows[0], columns))
+	return nil
+}
+
+// List multiple records in the order and page of page
+func (s *Store) List(ctx context.Context, tables interface{}, page *query.Page, query interface{}, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, rows, err := s.find(tables, query, args)
+	if err != nil {
+		return err
+	}
+	return t.list(tables, rows, page, nil)
+}
+
+func (t *table) list(tables interface{}, rows []reflect.Value, page *query.Page, columns []string) error {
+	if err := t.sort(rows, page.Sort()); err != nil {
+		return err
+	}
+	start := page.Offset()
+	if start > len(rows) {
+		start = len(rows)
+	}
+	end := start + page.Size()
+	if end > len(rows) || page.Size() < 0 {
+		end = len(rows)
+	}
+
+	dest := reflect.ValueOf(tables).Elem()
+	list := reflect.MakeSlice(dest.Type(), 0, end-start)
+	for _, row := range rows[start:end] {
+		v := t.copy(row, columns)
+		if dest.Type().Elem().Kind() == reflect.Ptr {
+			v = v.Addr()
+		}
+		list = reflect.Append(list, v)
+	}
+	dest.Set(list)
+	return nil
+}
+
+// Count number of records
+func (s *Store) Count(ctx context.Context, table interface{}, query interface{}, args ...interface{}) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, rows, err := s.find(table, query, args)
+	return int64(len(rows)), err
+}
+
+// ListByParams list the records matching the columns and filter of params, in the page and order of params,
+// with only the columns of params.Fields set, the params are validated as by database.ListByParams
+func (s *Store) ListByParams(ctx context.Context, tables interface{}, params *query.Params) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page := query.NewPage(params.Page, params.Size, params.Sort)
+	t, rows, columns, err := s.findByParams(tables, params, page.SortColumns())
+	if err != nil {
+		return err
+	}
+	return t.list(tables, rows, page, columns)
+}
+
+// GetByParams get the first record matching the columns and filter of params, with only the columns of params.Fields set
+func (s *Store) GetByParams(ctx context.Context, table interface{}, params *query.Params) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, rows, columns, err := s.findByParams(table, params, nil)
+	if err != nil {
+		return err
+	}
+	return t.first(table, rows, columns)
+}
+
+func (s *Store) findByParams(model interface{}, params *query.Params, sortColumns []string) (*table, []reflect.Value, []string, error) {
+	t, err := s.table(model)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err = params.CheckColumns(t.schema.DBNames); err != nil {
+		return nil, nil, nil, err
+	}
+	where, args, err := params.ConvertToGormConditions(query.DialectSQLite)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if _, err = params.ConvertToIncludes(model); err != nil {
+		return nil, nil, nil, err
+	}
+	for _, column := range sortColumns {
+		if column == query.Relevance {
+			return nil, nil, nil, fmt.Errorf("sort by %s is not supported", query.Relevance)
+		}
+	}
+
+	var columns []string
+	if len(params.Fields) > 0 {
+		if columns, err = params.ConvertToFields(t.schema.DBNames); err != nil {
+			return nil, nil, nil, err
+		}
+		columns = append(append(columns, t.schema.PrimaryFieldDBNames...), sortColumns...)
+	}
+
+	cond, err := t.where(where, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rows, err := t.find(cond)
+	return t, rows, columns, err
+}
+
+// sort the rows by an order of query.Page, example: name ASC, id DESC, NULL is the smallest value
+func (t *table) sort(rows []reflect.Value, order string) error {
+	type key struct {
+		field *schema.Field
+		desc  bool
+	}
+	keys := []key{}
+	for _, item := range strings.Split(order, ",") {
+		words := strings.Fields(item)
+		if len(words) == 0 {
+			continue
+		}
+		field := t.schema.LookUpField(words[0])
+		if field == nil || field.DBName == "" {
+			return fmt.Errorf("no such column: %s", words[0])
+		}
+		keys = append(keys, key{field: field, desc: len(words) > 1 && strings.EqualFold(words[1], "desc")})
+	}
+
+	var err error
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			a, _ := k.field.ValueOf(context.Background(), rows[i])
+			b, _ := k.field.ValueOf(context.Background(), rows[j])
+			a, b = normalize(a), normalize(b)
+			n := 0
+			switch {
+			case a == nil && b == nil:
+			case a == nil:
+				n = -1
+			case b == nil:
+				n = 1
+			default:
+				if n, err = compare(a, b); err != nil {
+					return false
+				}
+			}
+			if k.desc {
+				n = -n
+			}
+			if n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+	return err
+}
+
+// a copy of the row, with only the columns set when there are some
+func (t *table) copy(row reflect.Value, columns []string) reflect.Value {
+	v := reflect.New(row.Type()).Elem()
+	if len(columns) == 0 {
+		v.Set(row)
+		return v
+	}
+	for _, column := range columns {
+		if field := t.schema.LookUpField(column); field != nil {
+			value, _ := field.ValueOf(context.Background(), row)
+			_ = field.Set(context.Background(), v, value)
+		}
+	}
+	return v
+}
+
+func isInteger(field *schema.Field) bool {
+	return field.DataType == schema.Int || field.DataType == schema.Uint
+}
+
+// the value of an autoCreateTime or autoUpdateTime field
+func timestamp(field *schema.Field, now time.Time) interface{} {
+	timeType := field.AutoCreateTime
+	if field.AutoUpdateTime > 0 {
+		timeType = field.AutoUpdateTime
+	}
+	switch {
+	case field.DataType == schema.Time:
+		return now
+	case timeType == schema.UnixNanosecond:
+		return now.UnixNano()
+	case timeType == schema.UnixMillisecond:
+		return now.UnixMilli()
+	}
+	return now.Unix()
+}
@@ -0,0 +1,191 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+)
+
+type userExample struct {
+	database.Model `gorm:"embedded"`
+	Name           string  `gorm:"column:name;type:varchar(40);uniqueIndex" json:"name"`
+	Age            int     `gorm:"column:age" json:"age"`
+	Email          *string `gorm:"column:email" json:"email"`
+	Attrs          string  `gorm:"column:attrs;type:json" json:"attrs"`
+}
+
+type tagExample struct {
+	Code  string `gorm:"column:code;primaryKey"`
+	Label string `gorm:"column:label"`
+}
+
+// the same behaviour is expected from the fake and a database
+func TestStore(t *testing.T) {
+	stores := map[string]database.Store{
+		"fake":   New(),
+		"sqlite": database.NewStore(dbtest.New(t, &userExample{}, &tagExample{})),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, store)
+		})
+	}
+}
+
+func testStore(t *testing.T, store database.Store) {
+	ctx := context.Background()
+	email := "bob@example.com"
+	users := []*userExample{
+		{Name: "alice", Age: 30, Attrs: `{"color":"red","tags":["a","b"]}`},
+		{Name: "bob", Age: 20, Email: &email, Attrs: `{"color":"blue","tags":["b"]}`},
+	}
+	require.NoError(t, store.Create(ctx, &users))
+	carol := &userExample{Name: "carol", Age: 40, Attrs: "{}"}
+	require.NoError(t, store.Create(ctx, carol))
+	assert.Equal(t, uint64(1), users[0].ID)
+	assert.Equal(t, uint64(3), carol.ID)
+	assert.False(t, carol.CreatedAt.IsZero())
+
+	got := &userExample{}
+	require.NoError(t, store.Get(ctx, got, "name = ? AND age > ?", "bob", 10))
+	assert.Equal(t, "bob", got.Name)
+	assert.Equal(t, email, *got.Email)
+	got = &userExample{}
+	require.NoError(t, store.GetByID(ctx, got, 3))
+	assert.Equal(t, "carol", got.Name)
+	assert.ErrorIs(t, store.GetByID(ctx, &userExample{}, 100), query.ErrNotFound)
+	assert.ErrorIs(t, store.Get(ctx, &userExample{}, "email IS NULL AND name IN ?", []string{"bob", "dave"}), query.ErrNotFound)
+
+	names := func(list []*userExample) []string {
+		s := []string{}
+		for _, u := range list {
+			s = append(s, u.Name)
+		}
+		return s
+	}
+	list := []*userExample{}
+	require.NoError(t, store.List(ctx, &list, query.NewPage(0, 2, "-age"), "age >= ? OR name LIKE ?", 25, "b%"))
+	assert.Equal(t, []string{"carol", "alice"}, names(list))
+	require.NoError(t, store.List(ctx, &list, query.NewPage(1, 2, "-age"), "age >= ? OR name LIKE ?", 25, "b%"))
+	assert.Equal(t, []string{"bob"}, names(list))
+
+	count, err := store.Count(ctx, &userExample{}, query.Where(query.Col("age").Gt(15), query.Not(query.Col("name").In("alice", "bob"))))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = store.Count(ctx, &userExample{}, database.KV{"name": "bob", "age": 20})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	require.NoError(t, store.Update(ctx, &userExample{}, "age", 21, "id = ?", 2))
+	require.NoError(t, store.Updates(ctx, &userExample{}, database.KV{"age": 41, "email": "c@example.com"}, "name = ?", "carol"))
+	got = &userExample{}
+	require.NoError(t, store.Get(ctx, got, "email = ?", "c@example.com"))
+	assert.Equal(t, 41, got.Age)
+	assert.ErrorIs(t, store.Updates(ctx, &userExample{}, database.KV{"age": 1}, ""), gorm.ErrMissingWhereClause)
+	// an update is atomic, no row is changed when one of them fails
+	assert.Error(t, store.Updates(ctx, &userExample{}, database.KV{"name": "dave", "age": 99}, "age > ?", 15))
+	count, err = store.Count(ctx, &userExample{}, "name = ? OR age = ?", "dave", 99)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	assert.ErrorIs(t, store.Delete(ctx, &userExample{}, nil), gorm.ErrMissingWhereClause)
+
+	params := &query.Params{Size: 10, Sort: "age", Filter: `attrs.color = "red" or age < 30`, Fields: []string{"name"}}
+	require.NoError(t, store.ListByParams(ctx, &list, params))
+	assert.Equal(t, []string{"bob", "alice"}, names(list))
+	assert.Equal(t, uint64(2), list[0].ID)
+	assert.Empty(t, list[0].Attrs) // not selected
+	params = &query.Params{Columns: []query.Column{{Name: "age", Exp: query.Gt, Value: 40}}}
+	got = &userExample{}
+	require.NoError(t, store.GetByParams(ctx, got, params))
+	assert.Equal(t, "carol", got.Name)
+	assert.Error(t, store.ListByParams(ctx, &list, &query.Params{Size: 10, Filter: "secret = 1"}))
+	params = &query.Params{Size: 10, Sort: "id", Filter: `name match "-bob"`}
+	require.NoError(t, store.ListByParams(ctx, &list, params))
+	assert.Equal(t, []string{"alice", "carol"}, names(list))
+
+	require.NoError(t, store.DeleteByID(ctx, &userExample{}, 1))
+	assert.ErrorIs(t, store.GetByID(ctx, &userExample{}, 1), query.ErrNotFound)
+	count, err = store.Count(ctx, &userExample{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, store.Create(ctx, &[]tagExample{{Code: "a", Label: "A"}, {Code: "b", Label: "B"}}))
+	require.NoError(t, store.Delete(ctx, &tagExample{}, "code = ?", "a"))
+	tags := []tagExample{}
+	require.NoError(t, store.List(ctx, &tags, query.NewPage(0, 10, "code"), nil))
+	assert.Equal(t, []tagExample{{Code: "b", Label: "B"}}, tags)
+}
+
+func TestStore_fake(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := New(WithNow(func() time.Time { return now }))
+
+	user := &userExample{Name: "alice"}
+	require.NoError(t, store.Create(ctx, user))
+	assert.Equal(t, now, user.CreatedAt)
+	assert.ErrorIs(t, store.Create(ctx, &userExample{Name: "alice"}), query.ErrDuplicateKey)
+	assert.ErrorIs(t, store.Create(ctx, &userExample{Model: database.Model{ID: 1}, Name: "bob"}), query.ErrDuplicateKey)
+
+	// a failed batch creates nothing
+	assert.ErrorIs(t, store.Create(ctx, &[]userExample{{Name: "carol"}, {Name: "carol"}}), query.ErrDuplicateKey)
+	count, err := store.Count(ctx, &userExample{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	require.NoError(t, store.DeleteByID(ctx, &userExample{}, user.ID))
+	assert.Equal(t, now, store.tables["user_example"].rows[0].Interface().(userExample).DeletedAt.Time)
+
+	assert.EqualError(t, store.Get(ctx, &userExample{}, "lower(name) = ?", "alice"), "no such column: lower")
+	assert.EqualError(t, store.Get(ctx, &userExample{}, "name = ?"), "condition 'name = ?' has 1 placeholders for 0 arguments")
+	assert.Error(t, store.Get(ctx, &userExample{}, 1))
+	params := &query.Params{Size: 10, Sort: "-relevance", Columns: []query.Column{{Name: "name", Exp: query.Match, Value: "bob"}}}
+	assert.EqualError(t, store.ListByParams(ctx, &[]userExample{}, params), "sort by relevance is not supported")
+}
+
+// gorm adds the non-zero primary key of the model to the conditions of Updates and Delete
+func TestStore_primaryKey(t *testing.T) {
+	stores := map[string]database.Store{
+		"fake":   New(),
+		"sqlite": database.NewStore(dbtest.New(t, &userExample{}, &tagExample{})),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users := []*userExample{{Name: "alice", Age: 1}, {Name: "bob", Age: 1}, {Name: "carol", Age: 1}}
+			for _, user := range users {
+				require.NoError(t, store.Create(ctx, user))
+			}
+
+			require.NoError(t, store.Updates(ctx, &userExample{Model: database.Model{ID: users[1].ID}}, database.KV{"age": 2}, "age = ?", 1))
+			count, err := store.Count(ctx, &userExample{}, "age = ?", 2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+
+			require.NoError(t, store.Update(ctx, &userExample{Model: database.Model{ID: users[2].ID}}, "age", 3, nil))
+			got := &userExample{}
+			require.NoError(t, store.GetByID(ctx, got, users[2].ID))
+			assert.Equal(t, 3, got.Age)
+
+			require.NoError(t, store.Delete(ctx, &userExample{Model: database.Model{ID: users[0].ID}}, nil))
+			count, err = store.Count(ctx, &userExample{}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), count)
+			assert.ErrorIs(t, store.Delete(ctx, &userExample{}, nil), gorm.ErrMissingWhereClause)
+
+			require.NoError(t, store.Create(ctx, &tagExample{Code: "a", Label: "A"}))
+			require.NoError(t, store.Create(ctx, &tagExample{Code: "b", Label: "B"}))
+			require.NoError(t, store.Updates(ctx, &tagExample{Code: "b"}, database.KV{"label": "X"}, "label <> ?", ""))
+			tags := []tagExample{}
+			require.NoError(t, store.List(ctx, &tags, query.NewPage(0, 10, "code"), nil))
+			assert.Equal(t, []tagExample{{Code: "a", Label: "A"}, {Code: "b", Label: "X"}}, tags)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package fake
+
+import "time"
+
+// Option set the store options.
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	return &options{
+		now: time.Now,
+	}
+}
+
+// WithNow set the clock of the created_at, updated_at and deleted_at columns, default is time.Now
+func WithNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
@@ -0,0 +1,133 @@
+package fake
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// time formats of the strings compared with times
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// normalize a value of a field or an argument, nil is NULL, numbers are float64,
+// the values of the driver.Valuer types are used, example: gorm.DeletedAt, sql.NullString
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			return value
+		}
+		return normalize(value)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+	}
+	return v
+}
+
+// compare two normalized values that are not nil, the strings compared with numbers or times are converted
+func compare(a interface{}, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := toFloat(b); ok {
+			return compareFloat(x, y), nil
+		}
+	case bool:
+		if y, ok := toFloat(b); ok {
+			return compareFloat(boolFloat(x), y), nil
+		}
+	case time.Time:
+		if y, ok := toTime(b); ok {
+			return x.Compare(y), nil
+		}
+	case string:
+		switch b.(type) {
+		case float64, bool:
+			n, err := compare(b, a)
+			return -n, err
+		case time.Time:
+			n, err := compare(b, a)
+			return -n, err
+		case string:
+			return strings.Compare(x, b.(string)), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func compareFloat(x float64, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case bool:
+		return boolFloat(x), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, x, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
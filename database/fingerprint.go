@@ -0,0 +1,111 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	inListRegexp = regexp.MustCompile(`\(\?(, \?)+\)`)
+	valuesRegexp = regexp.MustCompile(`\(\?\+\)(, \(\?\+\))+`)
+)
+
+// Fingerprint normalize a SQL statement so that statements differing only in their values are equal,
+// string and number literals are replaced by ?, lists of values are collapsed to (?+),
+// whitespace is collapsed and everything outside quoted identifiers is lower-cased, example:
+//
+//	SELECT * FROM `user` WHERE name = 'bob' AND id IN (1, 2, 3)
+//	select * from `user` where name = ? and id in (?+)
+func Fingerprint(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	space := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '\'' || c == '"':
+			i = skipQuoted(sql, i)
+			c = '?'
+		case c == '`':
+			end := strings.IndexByte(sql[i+1:], '`')
+			if end < 0 {
+				end = len(sql) - i - 1
+			}
+			writeSpace(&b, &space)
+			b.WriteString(sql[i : i+end+2])
+			i += end + 1
+			continue
+		case isDigit(c) && (space || !isIdentChar(lastByte(&b))):
+			for i+1 < len(sql) && (isIdentChar(sql[i+1]) || sql[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		case c >= 'A' && c <= 'Z':
+			c += 'a' - 'A'
+		}
+
+		if c == ',' || c == ')' {
+			space = false
+		}
+		writeSpace(&b, &space)
+		b.WriteByte(c)
+		if c == ',' {
+			space = true
+		}
+		if c == '(' {
+			space = false
+			for i+1 < len(sql) && (sql[i+1] == ' ' || sql[i+1] == '\t' || sql[i+1] == '\n' || sql[i+1] == '\r') {
+				i++
+			}
+		}
+	}
+
+	s := inListRegexp.ReplaceAllString(b.String(), "(?+)")
+	s = strings.ReplaceAll(s, "(?)", "(?+)")
+	return valuesRegexp.ReplaceAllString(s, "(?+)")
+}
+
+// index of the closing quote, doubled and backslash escaped quotes are skipped
+func skipQuoted(sql string, start int) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(sql) - 1
+}
+
+func writeSpace(b *strings.Builder, space *bool) {
+	if *space && b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	*space = false
+}
+
+func lastByte(b *strings.Builder) byte {
+	s := b.String()
+	if s == "" {
+		return 0
+	}
+	return s[len(s)-1]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
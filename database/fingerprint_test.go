@@ -0,0 +1,35 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			sql:  "SELECT * FROM `user` WHERE name = 'bob' AND id IN (1, 2, 3)",
+			want: "select * from `user` where name = ? and id in (?+)",
+		},
+		{
+			sql:  "select *  from `User`\n where name = \"it''s\" and age > 20.5 limit 10",
+			want: "select * from `User` where name = ? and age > ? limit ?",
+		},
+		{
+			sql:  "INSERT INTO `t1` (`a`,`b`) VALUES ('x\\'y',1),('z',2)",
+			want: "insert into `t1` (`a`, `b`) values (?+)",
+		},
+		{
+			sql:  "SELECT col2 FROM t WHERE id IN ( 7 )",
+			want: "select col2 from t where id in (?+)",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Fingerprint(tt.sql))
+	}
+}
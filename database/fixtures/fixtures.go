@@ -0,0 +1,395 @@
+// Package fixtures seeds a database from YAML or JSON files, one file per table.
+//
+// Each file maps a row label to its column values, example users.yml:
+//
+//	alice:
+//	  name: Alice
+//	  created_at: "{{ now }}"
+//
+// and articles.yml:
+//
+//	hello:
+//	  title: Hello
+//	  author_id: $users.alice
+//	  token: "{{ uuid }}"
+//
+// A value of the form $table.label is replaced by the id of the referenced row,
+// the rows without an id of a table whose primary key is id get a stable id derived from their label,
+// the rows of the other tables, such as join tables, are inserted as they are and cannot be referenced.
+// Supported templates are {{ now }}, {{ today }} and {{ uuid }}, now and today accept an offset such as {{ now -24h }}.
+// A value starting with $$ is inserted literally with one leading $ removed.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xingmoo/library/utils"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	idColumn   = "id"
+	maxID      = 1<<30 - 1
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+var templateRegexp = regexp.MustCompile(`\{\{\s*(\w+)\s*([+-]\s*\w+)?\s*\}\}`)
+
+type row struct {
+	label  string
+	values map[string]interface{}
+	refs   []string // tables referenced by the row
+}
+
+type fixture struct {
+	table string
+	rows  []*row
+}
+
+// Load read the fixture files and insert their rows into db inside a single transaction
+func Load(ctx context.Context, db *gorm.DB, opts ...Option) error {
+	o := defaultOptions()
+	o.apply(opts...)
+
+	files := o.files
+	if len(files) == 0 {
+		var err error
+		files, err = listFiles(o.dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	fixtures := make([]*fixture, 0, len(files))
+	for _, file := range files {
+		f, err := parseFile(file)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	keyed, err := idTables(db.WithContext(ctx), fixtures)
+	if err != nil {
+		return err
+	}
+	if err = resolve(fixtures, keyed, o.now()); err != nil {
+		return err
+	}
+	fixtures = sortFixtures(fixtures)
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if o.truncate {
+			for i := len(fixtures) - 1; i >= 0; i-- {
+				if err := tx.Exec("DELETE FROM ?", clause.Table{Name: fixtures[i].table}).Error; err != nil {
+					return fmt.Errorf("fixtures: truncate %s error, err: %w", fixtures[i].table, err)
+				}
+			}
+		}
+
+		for _, f := range fixtures {
+			for _, r := range f.rows {
+				if err := tx.Table(f.table).Create(r.values).Error; err != nil {
+					return fmt.Errorf("fixtures: insert %s.%s error, err: %w", f.table, r.label, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// list the fixture files of a directory in name order
+func listFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: read directory %s error, err: %w", dir, err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yml", ".yaml", ".json":
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func parseFile(file string) (*fixture, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: read file %s error, err: %w", file, err)
+	}
+
+	name := filepath.Base(file)
+	ext := filepath.Ext(name)
+	f, err := parse(strings.TrimSuffix(name, ext), ext, data)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: parse file %s error, err: %w", file, err)
+	}
+	return f, nil
+}
+
+func parse(table string, ext string, data []byte) (*fixture, error) {
+	labels := map[string]map[string]interface{}{}
+	switch strings.ToLower(ext) {
+	case ".yml", ".yaml":
+		if err := yaml.Unmarshal(data, &labels); err != nil {
+			return nil, err
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&labels); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fixture format '%s'", ext)
+	}
+
+	f := &fixture{table: table, rows: make([]*row, 0, len(labels))}
+	for label, values := range labels {
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		f.rows = append(f.rows, &row{label: label, values: values})
+	}
+	sort.Slice(f.rows, func(i, j int) bool { return f.rows[i].label < f.rows[j].label })
+
+	return f, nil
+}
+
+// the tables of the fixtures whose primary key is the id column
+func idTables(db *gorm.DB, fixtures []*fixture) (map[string]bool, error) {
+	keyed := map[string]bool{}
+	for _, f := range fixtures {
+		columns, err := db.Migrator().ColumnTypes(f.table)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: read columns of %s error, err: %w", f.table, err)
+		}
+		for _, column := range columns {
+			if pk, ok := column.PrimaryKey(); ok && pk && column.Name() == idColumn {
+				keyed[f.table] = true
+			}
+		}
+	}
+	return keyed, nil
+}
+
+// fill in missing ids of the keyed tables, then replace references and templates with concrete values
+func resolve(fixtures []*fixture, keyed map[string]bool, now time.Time) error {
+	ids := map[string]interface{}{}
+	tables := map[string]bool{}
+	for _, f := range fixtures {
+		if tables[f.table] {
+			return fmt.Errorf("fixtures: duplicate fixture for table '%s'", f.table)
+		}
+		tables[f.table] = true
+
+		labels := map[string]string{} // label of each id of the table
+		for _, r := range f.rows {
+			id, ok := r.values[idColumn]
+			if !ok && !keyed[f.table] {
+				continue
+			}
+			if !ok {
+				id = labelID(r.label)
+				r.values[idColumn] = id
+			}
+			if other, ok := labels[fmt.Sprint(id)]; ok {
+				return fmt.Errorf("fixtures: %s.%s and %s.%s have the same id %v, set the id of one of them", f.table, other, f.table, r.label, id)
+			}
+			labels[fmt.Sprint(id)] = r.label
+			ids[f.table+"."+r.label] = id
+		}
+	}
+
+	for _, f := range fixtures {
+		for _, r := range f.rows {
+			for column, value := range r.values {
+				if s, ok := value.(string); ok && strings.HasPrefix(s, "$") && !strings.HasPrefix(s, "$$") {
+					r.refs = append(r.refs, strings.SplitN(s[1:], ".", 2)[0])
+				}
+				v, err := resolveValue(value, ids, now)
+				if err != nil {
+					return fmt.Errorf("fixtures: %s.%s column '%s': %w", f.table, r.label, column, err)
+				}
+				r.values[column] = v
+			}
+		}
+	}
+	return nil
+}
+
+func resolveValue(value interface{}, ids map[string]interface{}, now time.Time) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:], nil
+		}
+		if strings.HasPrefix(v, "$") {
+			id, ok := ids[v[1:]]
+			if !ok {
+				return nil, fmt.Errorf("unknown reference '%s'", v)
+			}
+			return id, nil
+		}
+		return expandTemplates(v, now)
+	case map[string]interface{}, []interface{}:
+		// nested values are stored in JSON columns
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// a value that is a single template keeps its type, templates embedded in text are formatted as strings
+func expandTemplates(s string, now time.Time) (interface{}, error) {
+	if loc := templateRegexp.FindStringIndex(s); loc != nil && loc[0] == 0 && loc[1] == len(s) {
+		return evalTemplate(templateRegexp.FindStringSubmatch(s), now)
+	}
+
+	var err error
+	out := templateRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		v, e := evalTemplate(templateRegexp.FindStringSubmatch(match), now)
+		if e != nil {
+			err = e
+			return match
+		}
+		if t, ok := v.(time.Time); ok {
+			return t.Format(timeLayout)
+		}
+		return fmt.Sprint(v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func evalTemplate(match []string, now time.Time) (interface{}, error) {
+	name, offset := match[1], strings.ReplaceAll(match[2], " ", "")
+
+	var t time.Time
+	switch name {
+	case "now":
+		t = now
+	case "today":
+		y, m, d := now.Date()
+		t = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	case "uuid":
+		if offset != "" {
+			return nil, fmt.Errorf("template '%s' does not accept an offset", name)
+		}
+		return utils.UUIDv4(), nil
+	default:
+		return nil, fmt.Errorf("unknown template '%s'", name)
+	}
+
+	if offset != "" {
+		d, err := parseOffset(offset)
+		if err != nil {
+			return nil, err
+		}
+		t = t.Add(d)
+	}
+	return t, nil
+}
+
+// time.ParseDuration plus a 'd' unit for days, example: -24h, +7d
+func parseOffset(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		d, err := time.ParseDuration(strings.TrimSuffix(s, "d") + "h")
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset '%s'", s)
+		}
+		return d * 24, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset '%s'", s)
+	}
+	return d, nil
+}
+
+// stable id derived from the row label, so references do not depend on insertion order
+func labelID(label string) uint64 {
+	return uint64(crc32.ChecksumIEEE([]byte(label)) % maxID)
+}
+
+// order the fixtures so that referenced tables are inserted first,
+// tables taking part in a reference cycle keep their name order at the end
+func sortFixtures(fixtures []*fixture) []*fixture {
+	byTable := map[string]*fixture{}
+	for _, f := range fixtures {
+		byTable[f.table] = f
+	}
+
+	deps := map[string]map[string]bool{}
+	for _, f := range fixtures {
+		deps[f.table] = map[string]bool{}
+		for _, r := range f.rows {
+			for _, ref := range r.refs {
+				if ref != f.table && byTable[ref] != nil {
+					deps[f.table][ref] = true
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(fixtures))
+	for _, f := range fixtures {
+		names = append(names, f.table)
+	}
+	sort.Strings(names)
+
+	sorted := make([]*fixture, 0, len(fixtures))
+	done := map[string]bool{}
+	for len(sorted) < len(names) {
+		progress := false
+		for _, name := range names {
+			if done[name] || !allDone(deps[name], done) {
+				continue
+			}
+			done[name] = true
+			sorted = append(sorted, byTable[name])
+			progress = true
+		}
+		if !progress {
+			for _, name := range names {
+				if !done[name] {
+					done[name] = true
+					sorted = append(sorted, byTable[name])
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+func allDone(deps map[string]bool, done map[string]bool) bool {
+	for dep := range deps {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
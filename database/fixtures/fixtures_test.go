@@ -0,0 +1,123 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database/dbtest"
+)
+
+func TestParse(t *testing.T) {
+	f, err := parse("users", ".yml", []byte("bob:\n  name: Bob\nalice:\n  name: Alice\n  age: 20\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "users", f.table)
+	assert.Equal(t, "alice", f.rows[0].label)
+	assert.Equal(t, 20, f.rows[0].values["age"])
+
+	f, err = parse("users", ".json", []byte(`{"alice": {"name": "Alice", "attrs": {"vip": true}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", f.rows[0].values["name"])
+
+	_, err = parse("users", ".xml", nil)
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	users, _ := parse("users", ".yml", []byte("alice:\n  id: 7\n  created_at: '{{ now }}'\n  attrs: {vip: true}\nbob:\n  note: '$$cash'\n"))
+	articles, _ := parse("articles", ".yml", []byte("hello:\n  author_id: $users.alice\n  editor_id: $users.bob\n  title: 'posted {{ today -1d }}'\n  token: '{{ uuid }}'\n"))
+
+	err := resolve([]*fixture{users, articles}, map[string]bool{"users": true, "articles": true}, now)
+	assert.NoError(t, err)
+
+	alice, bob, hello := users.rows[0].values, users.rows[1].values, articles.rows[0].values
+	assert.Equal(t, now, alice["created_at"])
+	assert.Equal(t, `{"vip":true}`, alice["attrs"])
+	assert.Equal(t, "$cash", bob["note"])
+	assert.Equal(t, labelID("bob"), bob["id"])
+	assert.Equal(t, 7, hello["author_id"])
+	assert.Equal(t, labelID("bob"), hello["editor_id"])
+	assert.Equal(t, "posted 2026-01-01 00:00:00", hello["title"])
+	assert.Len(t, hello["token"], 36)
+
+	// referenced tables are inserted first
+	sorted := sortFixtures([]*fixture{users, articles})
+	assert.Equal(t, "users", sorted[0].table)
+	sorted = sortFixtures([]*fixture{articles, users})
+	assert.Equal(t, "users", sorted[0].table)
+}
+
+func TestResolveError(t *testing.T) {
+	articles, _ := parse("articles", ".yml", []byte("hello:\n  author_id: $users.nobody\n"))
+	assert.Error(t, resolve([]*fixture{articles}, nil, time.Now()))
+
+	articles, _ = parse("articles", ".yml", []byte("hello:\n  title: '{{ yesterday }}'\n"))
+	assert.Error(t, resolve([]*fixture{articles}, nil, time.Now()))
+
+	articles, _ = parse("articles", ".yml", []byte("hello:\n  title: '{{ now +3x }}'\n"))
+	assert.Error(t, resolve([]*fixture{articles}, nil, time.Now()))
+
+	// a generated id equal to the id of another row
+	users, _ := parse("users", ".yml", []byte(fmt.Sprintf("alice:\n  id: %d\nbob:\n  name: Bob\n", labelID("bob"))))
+	err := resolve([]*fixture{users}, map[string]bool{"users": true}, time.Now())
+	assert.EqualError(t, err, fmt.Sprintf("fixtures: users.alice and users.bob have the same id %d, set the id of one of them", labelID("bob")))
+
+	// no id is generated for a table whose primary key is not id, its rows cannot be referenced
+	tags, _ := parse("article_tags", ".yml", []byte("hello_go:\n  article_id: 1\n  tag: go\n"))
+	assert.NoError(t, resolve([]*fixture{tags}, map[string]bool{}, time.Now()))
+	assert.NotContains(t, tags.rows[0].values, "id")
+	articles, _ = parse("articles", ".yml", []byte("hello:\n  tag: $article_tags.hello_go\n"))
+	assert.Error(t, resolve([]*fixture{tags, articles}, map[string]bool{"articles": true}, time.Now()))
+}
+
+type fixtureUser struct {
+	ID   uint64 `gorm:"column:id;primary_key"`
+	Name string `gorm:"column:name"`
+}
+
+type fixtureArticle struct {
+	ID       uint64 `gorm:"column:id;primary_key"`
+	AuthorID uint64 `gorm:"column:author_id"`
+	Title    string `gorm:"column:title"`
+}
+
+type fixtureArticleTag struct {
+	ArticleID uint64 `gorm:"column:article_id;primaryKey"`
+	Tag       string `gorm:"column:tag;primaryKey"`
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &fixtureUser{}, &fixtureArticle{}, &fixtureArticleTag{})
+	dir := t.TempDir()
+	files := map[string]string{
+		"fixture_user.yml":        "alice:\n  name: Alice\nbob:\n  id: 2\n  name: Bob\n",
+		"fixture_article.yml":     "hello:\n  author_id: $fixture_user.alice\n  title: Hello\n",
+		"fixture_article_tag.yml": "hello_go:\n  article_id: $fixture_article.hello\n  tag: go\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	assert.NoError(t, Load(ctx, db, WithDirectory(dir)))
+	assert.NoError(t, Load(ctx, db, WithDirectory(dir), WithTruncate()))
+
+	users := []fixtureUser{}
+	assert.NoError(t, db.Order("name").Find(&users).Error)
+	assert.Equal(t, []fixtureUser{{ID: labelID("alice"), Name: "Alice"}, {ID: 2, Name: "Bob"}}, users)
+	article := &fixtureArticle{}
+	assert.NoError(t, db.First(article).Error)
+	assert.Equal(t, labelID("alice"), article.AuthorID)
+	tags := []fixtureArticleTag{}
+	assert.NoError(t, db.Find(&tags).Error)
+	assert.Equal(t, []fixtureArticleTag{{ArticleID: labelID("hello"), Tag: "go"}}, tags)
+
+	// a missing table
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "missing.yml"), []byte("a:\n  name: A\n"), 0o644))
+	assert.Error(t, Load(ctx, db, WithDirectory(dir)))
+}
@@ -0,0 +1,57 @@
+package fixtures
+
+import "time"
+
+// Option set the fixture loader options.
+type Option func(*options)
+
+type options struct {
+	dir      string
+	files    []string
+	truncate bool
+
+	now func() time.Time
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	return &options{
+		dir: "testdata/fixtures", // directory scanned when no files are specified
+		now: time.Now,
+	}
+}
+
+// WithDirectory load every .yml, .yaml and .json file in the directory
+func WithDirectory(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+// WithFiles load only the given fixture files, the table name is the file name without extension
+func WithFiles(files ...string) Option {
+	return func(o *options) {
+		o.files = append(o.files, files...)
+	}
+}
+
+// WithTruncate delete all rows of the fixture tables before inserting, in the same transaction,
+// the rows are deleted with DELETE FROM and not TRUNCATE, so the AUTO_INCREMENT counters are not reset
+func WithTruncate() Option {
+	return func(o *options) {
+		o.truncate = true
+	}
+}
+
+// WithNow set the clock used by the {{ now }} and {{ today }} templates
+func WithNow(fn func() time.Time) Option {
+	return func(o *options) {
+		o.now = fn
+	}
+}
@@ -0,0 +1,403 @@
+// Package jobs runs background jobs from a queue stored in a table, the jobs survive restarts without a broker.
+//
+// Enqueue a job, inside the transaction that changes the data it is about when there is one, example:
+//
+//	_, err := jobs.Enqueue(ctx, db, "email", Welcome{UserID: user.ID}, jobs.WithUniqueKey(fmt.Sprint(user.ID)))
+//
+// and run workers that claim the due jobs of the queue and handle them:
+//
+//	go jobs.NewWorker(db, "email", jobs.HandlerFunc(sendWelcome), jobs.WithConcurrency(8)).Run(ctx)
+//
+// A failed job is retried with an exponential backoff and is dead after the maximum number of attempts,
+// the dead jobs stay in the table until they are requeued with Requeue or deleted.
+// A job runs at least once, it may run again if its worker stops before recording the result, handlers should be idempotent.
+// Every claim of a job counts as an attempt, so a job whose worker keeps crashing is dead too.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/query"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// job status
+const (
+	StatusPending = 0 // waiting to run, or running while it is leased
+	StatusDone    = 1 // handled successfully
+	StatusDead    = 2 // gave up after the maximum number of attempts
+)
+
+// Job row of the jobs table
+type Job struct {
+	ID          uint64     `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	Queue       string     `gorm:"column:queue;type:varchar(128);not null;index:idx_job_poll,priority:1;uniqueIndex:uk_job_unique_key,priority:1" json:"queue"`
+	UniqueKey   *string    `gorm:"column:unique_key;type:varchar(255);uniqueIndex:uk_job_unique_key,priority:2" json:"unique_key,omitempty"`
+	Payload     string     `gorm:"column:payload;type:text" json:"payload"`
+	Priority    int        `gorm:"column:priority;not null;default:0" json:"priority"`
+	Status      int        `gorm:"column:status;not null;default:0;index:idx_job_poll,priority:2" json:"status"`
+	RunAt       time.Time  `gorm:"column:run_at;index:idx_job_poll,priority:3" json:"run_at"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"column:last_error;type:text" json:"last_error"`
+	LockedBy    string     `gorm:"column:locked_by;type:varchar(255)" json:"-"`
+	LockedUntil *time.Time `gorm:"column:locked_until" json:"-"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	FinishedAt  *time.Time `gorm:"column:finished_at" json:"finished_at"`
+}
+
+// TableName of the jobs
+func (Job) TableName() string {
+	return "job"
+}
+
+// Decode unmarshal the json payload of the job into v
+func (j *Job) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(j.Payload), v); err != nil {
+		return fmt.Errorf("jobs: unmarshal payload of job %d error, err: %w", j.ID, err)
+	}
+	return nil
+}
+
+// Handler run a job, an error schedules a retry
+type Handler interface {
+	Handle(ctx context.Context, job *Job) error
+}
+
+// HandlerFunc adapt a function to the Handler interface
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Handle call f
+func (f HandlerFunc) Handle(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// AutoMigrate create or update the jobs table
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Job{})
+}
+
+// Enqueue add a job to the queue, db may be the transaction that changes the data the job is about,
+// payload is stored as it is when it is a string or []byte, otherwise as json.
+// a job with the unique key of an unfinished job of the queue is not added and the error is query.ErrDuplicateKey
+func Enqueue(ctx context.Context, db *gorm.DB, queue string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	o := &enqueueOptions{}
+	o.apply(opts...)
+
+	var data string
+	switch v := payload.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("jobs: marshal payload error, err: %w", err)
+		}
+		data = string(b)
+	}
+
+	job := &Job{
+		Queue:    queue,
+		Payload:  data,
+		Priority: o.priority,
+		Status:   StatusPending,
+		RunAt:    o.runAt,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if o.uniqueKey == "" {
+		return job, database.Create(ctx, db, job)
+	}
+
+	job.UniqueKey = &o.uniqueKey
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("jobs: job '%s' of queue '%s' is already enqueued, %w", o.uniqueKey, queue, query.ErrDuplicateKey)
+	}
+	return job, nil
+}
+
+// Requeue run a dead job again with its attempts reset, its unique key is not restored
+func Requeue(ctx context.Context, db *gorm.DB, id uint64) error {
+	result := db.WithContext(ctx).Model(&Job{}).Where("id = ? AND status = ?", id, StatusDead).
+		Updates(map[string]interface{}{"status": StatusPending, "attempts": 0, "run_at": time.Now(), "finished_at": nil})
+	if result.Error != nil {
+		return fmt.Errorf("jobs: requeue job %d error, err: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("jobs: dead job %d, %w", id, query.ErrNotFound)
+	}
+	return nil
+}
+
+// Worker run the due jobs of a queue with a handler
+type Worker struct {
+	db      *gorm.DB
+	queue   string
+	handler Handler
+	o       *options
+
+	now func() time.Time
+}
+
+// NewWorker create a worker of the queue, several workers may run on the same queue
+func NewWorker(db *gorm.DB, queue string, handler Handler, opts ...Option) *Worker {
+	o := defaultOptions()
+	o.apply(opts...)
+
+	return &Worker{
+		db:      db,
+		queue:   queue,
+		handler: handler,
+		o:       o,
+		now:     time.Now,
+	}
+}
+
+// Run claim and run jobs until ctx is done, then stop claiming and wait for the running jobs,
+// the context of the jobs is canceled when they are still running after the shutdown timeout
+func (w *Worker) Run(ctx context.Context) error {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	slots := make(chan struct{}, w.o.concurrency)
+	freed := make(chan struct{}, 1)
+	wg := &sync.WaitGroup{}
+
+	for ctx.Err() == nil {
+		free := cap(slots) - len(slots)
+		wait := w.o.pollInterval
+		if free > 0 {
+			jobs, err := w.claim(ctx, free)
+			if err != nil && ctx.Err() == nil {
+				w.o.logger.Error("jobs claim error", zap.String("queue", w.queue), zap.Error(err))
+			}
+			for _, job := range jobs {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func(job *Job) {
+					defer func() {
+						<-slots
+						wg.Done()
+						select {
+						case freed <- struct{}{}:
+						default:
+						}
+					}()
+					w.process(jobCtx, job)
+				}(job)
+			}
+			// keep claiming while jobs are found
+			if err == nil && len(jobs) > 0 && len(jobs) == free {
+				wait = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-freed:
+		case <-time.After(wait):
+		}
+	}
+
+	w.shutdown(wg, cancelJobs)
+	return ctx.Err()
+}
+
+// wait for the running jobs, and cancel them after the shutdown timeout
+func (w *Worker) shutdown(wg *sync.WaitGroup, cancelJobs context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(w.o.shutdownTimeout):
+		w.o.logger.Warn("jobs still running after the shutdown timeout are canceled", zap.String("queue", w.queue))
+		cancelJobs()
+		<-done
+	}
+}
+
+// WorkOnce claim a batch of due jobs, as many as the concurrency, run them and wait for them,
+// return the number of claimed jobs
+func (w *Worker) WorkOnce(ctx context.Context) (int, error) {
+	jobs, err := w.claim(ctx, w.o.concurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *Job) {
+			defer wg.Done()
+			w.process(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+// reserve due jobs of the queue for this worker by setting their lease, the highest priority first,
+// on mysql the candidates are selected with FOR UPDATE SKIP LOCKED so concurrent workers do not wait for each other
+func (w *Worker) claim(ctx context.Context, limit int) ([]*Job, error) {
+	now := w.now()
+	until := now.Add(w.o.lease)
+	claimed := []*Job{}
+
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("queue = ? AND status = ? AND run_at <= ?", w.queue, StatusPending, now).
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Order("priority DESC, run_at, id").Limit(limit)
+		if tx.Dialector.Name() == "mysql" {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		candidates := []*Job{}
+		if err := q.Find(&candidates).Error; err != nil {
+			return err
+		}
+
+		for _, job := range candidates {
+			// every claim counts as an attempt, the attempts of a job whose worker died before settling it are exhausted too
+			update := map[string]interface{}{"locked_by": w.o.workerID, "locked_until": until, "attempts": gorm.Expr("attempts + 1")}
+			exhausted := job.Attempts >= w.o.maxAttempts
+			if exhausted {
+				update = map[string]interface{}{"status": StatusDead, "finished_at": now, "unique_key": nil, "locked_by": "", "locked_until": nil}
+			}
+			result := tx.Model(&Job{}).
+				Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", job.ID, now).
+				Updates(update)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 { // claimed by another worker in the meantime
+				continue
+			}
+			if exhausted {
+				w.o.logger.Error("job is dead, its last attempt did not finish", zap.Uint64("id", job.ID), zap.String("queue", w.queue),
+					zap.Int("attempts", job.Attempts))
+				continue
+			}
+			job.Attempts++
+			job.LockedBy, job.LockedUntil = w.o.workerID, &until
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jobs: claim jobs error, err: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// run the job while renewing its lease, then record the result
+func (w *Worker) process(ctx context.Context, job *Job) {
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		w.renew(job, stop)
+	}()
+
+	err := w.handle(ctx, job)
+	close(stop)
+	<-renewed
+
+	if err = w.settle(job, err, ctx.Err() != nil); err != nil {
+		w.o.logger.Error("jobs settle error", zap.String("queue", w.queue), zap.Error(err))
+	}
+}
+
+// extend the lease of the running job every third of the lease until stop is closed
+func (w *Worker) renew(job *Job, stop <-chan struct{}) {
+	if w.o.lease/3 <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(w.o.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			result := w.db.Model(&Job{}).Where("id = ? AND locked_by = ?", job.ID, w.o.workerID).
+				Update("locked_until", w.now().Add(w.o.lease))
+			if result.Error != nil {
+				w.o.logger.Warn("jobs renew lease error", zap.Uint64("id", job.ID), zap.Error(result.Error))
+			} else if result.RowsAffected == 0 {
+				w.o.logger.Warn("jobs lease lost", zap.Uint64("id", job.ID), zap.String("queue", w.queue))
+			}
+		}
+	}
+}
+
+// a panicking handler counts as a failed run
+func (w *Worker) handle(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("handler panic: %v", e)
+		}
+	}()
+
+	return w.handler.Handle(ctx, job)
+}
+
+// record the result of a run, the attempt was counted by the claim,
+// a job interrupted by the shutdown is released and its attempt is given back
+func (w *Worker) settle(job *Job, runErr error, canceled bool) error {
+	update := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	switch {
+	case runErr == nil:
+		update["status"] = StatusDone
+		update["finished_at"] = w.now()
+		update["unique_key"] = nil
+	case canceled && errors.Is(runErr, context.Canceled):
+		update["attempts"] = gorm.Expr("attempts - 1")
+		w.o.logger.Warn("jobs run canceled", zap.Uint64("id", job.ID), zap.String("queue", w.queue))
+	default:
+		attempts := job.Attempts
+		update["last_error"] = runErr.Error()
+		if attempts >= w.o.maxAttempts {
+			update["status"] = StatusDead
+			update["finished_at"] = w.now()
+			update["unique_key"] = nil
+			w.o.logger.Error("job is dead", zap.Uint64("id", job.ID), zap.String("queue", w.queue),
+				zap.Int("attempts", attempts), zap.Error(runErr))
+		} else {
+			next := w.now().Add(database.Backoff(attempts, w.o.minBackoff, w.o.maxBackoff))
+			update["run_at"] = next
+			w.o.logger.Warn("jobs run error", zap.Uint64("id", job.ID), zap.String("queue", w.queue),
+				zap.Int("attempts", attempts), zap.Time("run_at", next), zap.Error(runErr))
+		}
+	}
+
+	// only the owner of the lease may settle the job, an expired lease may have been taken over.
+	// the result is recorded even when ctx is done, so that a finished job does not run again
+	err := w.db.WithContext(context.Background()).Model(&Job{}).
+		Where("id = ? AND locked_by = ?", job.ID, w.o.workerID).
+		Updates(update).Error
+	if err != nil {
+		return fmt.Errorf("jobs: update job %d error, err: %w", job.ID, err)
+	}
+	return nil
+}
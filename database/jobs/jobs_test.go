@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+)
+
+type welcome struct {
+	UserID uint64 `json:"user_id"`
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Job{})
+
+	job, err := Enqueue(ctx, db, "email", welcome{UserID: 1}, WithPriority(5), WithUniqueKey("user-1"))
+	assert.NoError(t, err)
+	assert.NotZero(t, job.ID)
+	v := welcome{}
+	assert.NoError(t, job.Decode(&v))
+	assert.Equal(t, uint64(1), v.UserID)
+
+	// unique among the unfinished jobs of the queue
+	_, err = Enqueue(ctx, db, "email", welcome{UserID: 1}, WithUniqueKey("user-1"))
+	assert.ErrorIs(t, err, query.ErrDuplicateKey)
+	_, err = Enqueue(ctx, db, "export", "{}", WithUniqueKey("user-1"))
+	assert.NoError(t, err)
+
+	w := NewWorker(db, "email", HandlerFunc(func(ctx context.Context, job *Job) error { return nil }))
+	n, err := w.WorkOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = Enqueue(ctx, db, "email", welcome{UserID: 1}, WithUniqueKey("user-1"))
+	assert.NoError(t, err)
+
+	done := &Job{}
+	assert.NoError(t, db.First(done, job.ID).Error)
+	assert.Equal(t, StatusDone, done.Status)
+	assert.NotNil(t, done.FinishedAt)
+	assert.Nil(t, done.UniqueKey)
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Job{})
+	_, err := Enqueue(ctx, db, "email", "low")
+	assert.NoError(t, err)
+	_, err = Enqueue(ctx, db, "email", "fail", WithPriority(1))
+	assert.NoError(t, err)
+	_, err = Enqueue(ctx, db, "email", "high", WithPriority(10))
+	assert.NoError(t, err)
+	_, err = Enqueue(ctx, db, "email", "later", WithRunAt(time.Now().Add(time.Hour)))
+	assert.NoError(t, err)
+
+	handled := []string{}
+	w := NewWorker(db, "email", HandlerFunc(func(ctx context.Context, job *Job) error {
+		if job.Payload == "fail" {
+			return errors.New("smtp unavailable")
+		}
+		handled = append(handled, job.Payload)
+		return nil
+	}), WithConcurrency(1), WithMaxAttempts(2), WithBackoff(time.Minute, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		n, err := w.WorkOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+	assert.Equal(t, []string{"high", "low"}, handled)
+
+	failed := &Job{}
+	assert.NoError(t, db.Where("payload = ?", "fail").First(failed).Error)
+	assert.Equal(t, StatusPending, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "smtp unavailable", failed.LastError)
+	assert.True(t, failed.RunAt.After(time.Now().Add(29*time.Second)))
+	assert.Nil(t, failed.LockedUntil)
+
+	// nothing due
+	n, err := w.WorkOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// second failure, the job is dead
+	w.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	n, err = w.WorkOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, db.First(failed, failed.ID).Error)
+	assert.Equal(t, StatusDead, failed.Status)
+
+	assert.NoError(t, Requeue(ctx, db, failed.ID))
+	assert.ErrorIs(t, Requeue(ctx, db, failed.ID), query.ErrNotFound)
+	assert.NoError(t, db.First(failed, failed.ID).Error)
+	assert.Equal(t, StatusPending, failed.Status)
+	assert.Equal(t, 0, failed.Attempts)
+}
+
+func TestWorker_lease(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Job{})
+	_, err := Enqueue(ctx, db, "export", "{}")
+	assert.NoError(t, err)
+
+	handler := HandlerFunc(func(ctx context.Context, job *Job) error { return nil })
+	w1 := NewWorker(db, "export", handler, WithWorkerID("w1"))
+	jobs, err := w1.claim(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	// leased by w1
+	assert.NotEqual(t, NewWorker(db, "export", handler).o.workerID, NewWorker(db, "export", handler).o.workerID)
+	w2 := NewWorker(db, "export", handler, WithWorkerID("w2"))
+	jobs, err = w2.claim(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 0)
+
+	// the lease expired, w1 may not settle the job any more
+	w2.now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	jobs, err = w2.claim(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "w2", jobs[0].LockedBy)
+	assert.NoError(t, w1.settle(jobs[0], nil, false))
+	job := &Job{}
+	assert.NoError(t, db.First(job, jobs[0].ID).Error)
+	assert.Equal(t, StatusPending, job.Status)
+}
+
+func TestWorker_Run(t *testing.T) {
+	db := dbtest.New(t, &Job{})
+	for _, payload := range []string{"a", "b", "c"} {
+		_, err := Enqueue(context.Background(), db, "email", payload)
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mu := sync.Mutex{}
+	handled := 0
+	w := NewWorker(db, "email", HandlerFunc(func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if handled++; handled == 3 {
+			cancel()
+		}
+		return nil
+	}), WithConcurrency(2), WithPollInterval(10*time.Millisecond))
+
+	err := w.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	var count int64
+	assert.NoError(t, db.Model(&Job{}).Where("status = ?", StatusDone).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestWorker_shutdown(t *testing.T) {
+	db := dbtest.New(t, &Job{})
+	job, err := Enqueue(context.Background(), db, "export", "{}")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWorker(db, "export", HandlerFunc(func(jobCtx context.Context, job *Job) error {
+		cancel()
+		<-jobCtx.Done() // still running after the shutdown timeout
+		return jobCtx.Err()
+	}), WithPollInterval(10*time.Millisecond), WithShutdownTimeout(20*time.Millisecond))
+
+	assert.ErrorIs(t, w.Run(ctx), context.Canceled)
+
+	// released without counting an attempt
+	assert.NoError(t, db.First(job, job.ID).Error)
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	assert.Nil(t, job.LockedUntil)
+}
+
+func TestWorker_crashed(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Job{})
+	job, err := Enqueue(ctx, db, "export", "{}", WithUniqueKey("export-1"))
+	assert.NoError(t, err)
+
+	// the worker dies while the job runs, the job is claimed again once its lease expired
+	w := NewWorker(db, "export", HandlerFunc(func(ctx context.Context, job *Job) error { return nil }),
+		WithMaxAttempts(2), WithLease(0))
+	assert.Equal(t, 5*time.Minute, w.o.lease)
+	for i := 1; i <= 2; i++ {
+		w.now = func() time.Time { return time.Now().Add(time.Duration(i) * 10 * time.Minute) }
+		jobs, err := w.claim(ctx, 10)
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 1)
+		assert.Equal(t, i, jobs[0].Attempts)
+	}
+
+	// the attempts are exhausted, the job is dead instead of claimed
+	w.now = func() time.Time { return time.Now().Add(30 * time.Minute) }
+	jobs, err := w.claim(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 0)
+	assert.NoError(t, db.First(job, job.ID).Error)
+	assert.Equal(t, StatusDead, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Nil(t, job.UniqueKey)
+	assert.Nil(t, job.LockedUntil)
+}
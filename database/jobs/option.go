@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xingmoo/library/utils"
+	"go.uber.org/zap"
+)
+
+// Option set the worker options.
+type Option func(*options)
+
+type options struct {
+	concurrency     int
+	pollInterval    time.Duration
+	lease           time.Duration
+	maxAttempts     int
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+	shutdownTimeout time.Duration
+	workerID        string
+
+	logger *zap.Logger
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	host, _ := os.Hostname()
+	return &options{
+		concurrency:     4,                // number of jobs run at the same time
+		pollInterval:    time.Second,      // wait between polls when no job is due
+		lease:           5 * time.Minute,  // time a claimed job is reserved for this worker, renewed while it runs
+		maxAttempts:     10,               // number of failed runs before a job is dead
+		minBackoff:      10 * time.Second, // delay before the first retry
+		maxBackoff:      time.Hour,
+		shutdownTimeout: 30 * time.Second, // wait for the running jobs when ctx is done
+		workerID:        fmt.Sprintf("%s-%d-%s", host, os.Getpid(), utils.UUIDv4()),
+		logger:          zap.NewNop(),
+	}
+}
+
+// WithConcurrency set the number of jobs a worker runs at the same time
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithPollInterval set the wait between polls when no job is due
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithLease set the time a claimed job is reserved, the lease is renewed while the job runs,
+// a job whose worker died is run again once its lease expired
+func WithLease(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.lease = d
+		}
+	}
+}
+
+// WithMaxAttempts set the number of failed runs after which a job is dead
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff set the bounds of the exponential retry delay
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(o *options) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithShutdownTimeout set the wait for the running jobs when the ctx of Run is done,
+// the context of the jobs still running after it is canceled
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithWorkerID set the id recorded on claimed jobs, it must be unique among the workers,
+// default is hostname-pid followed by a random uuid
+func WithWorkerID(id string) Option {
+	return func(o *options) {
+		o.workerID = id
+	}
+}
+
+// WithLogger set the logger of job failures
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// EnqueueOption set the options of an enqueued job.
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	priority  int
+	runAt     time.Time
+	uniqueKey string
+}
+
+func (o *enqueueOptions) apply(opts ...EnqueueOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithPriority set the priority of the job, the jobs with a higher priority run first, default is 0
+func WithPriority(priority int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.priority = priority
+	}
+}
+
+// WithRunAt set the time before which the job does not run, default is now
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+// WithUniqueKey set a key unique among the unfinished jobs of the queue,
+// enqueueing a job with the key of a pending or running job fails with query.ErrDuplicateKey
+func WithUniqueKey(key string) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.uniqueKey = key
+	}
+}
@@ -0,0 +1,256 @@
+// Package lock provides distributed locks stored in the database, example electing the runner of a cron job:
+//
+//	locker := lock.New(db)
+//	l, err := locker.TryAcquire(ctx, "daily-report", time.Minute)
+//	if errors.Is(err, lock.ErrNotAcquired) {
+//		return // another replica runs the job
+//	}
+//	defer l.Release(context.Background())
+//
+// On mysql GET_LOCK is used, the lock is held by a dedicated connection and is freed by mysql when the connection is lost.
+// On other dialects, or with WithMode(ModeLease), a row of the lease table is owned for ttl and renewed in the background,
+// the table is created by AutoMigrate. The lease expiry uses the clock of the application, keep the clocks of the hosts in sync.
+// A lock is released by Release or when the ctx passed to Acquire is done, Lost is closed if the lock is taken away.
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xingmoo/library/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotAcquired the lock is held by another owner
+var ErrNotAcquired = errors.New("lock is held by another owner")
+
+// Lease row of the lease table
+type Lease struct {
+	Name      string    `gorm:"column:name;type:varchar(255);primary_key" json:"name"`
+	Owner     string    `gorm:"column:owner;type:varchar(64);not null" json:"owner"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+}
+
+// TableName of the lease table
+func (Lease) TableName() string {
+	return "distributed_lock"
+}
+
+// AutoMigrate create or update the lease table
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Lease{})
+}
+
+// Locker acquire named locks
+type Locker struct {
+	db *gorm.DB
+	o  *options
+}
+
+// New create a locker
+func New(db *gorm.DB, opts ...Option) *Locker {
+	o := defaultOptions()
+	o.apply(opts...)
+	if o.mode == ModeAuto {
+		o.mode = ModeLease
+		if db.Dialector.Name() == "mysql" {
+			o.mode = ModeMySQL
+		}
+	}
+	return &Locker{db: db, o: o}
+}
+
+// Acquire wait until the lock is acquired or ctx is done,
+// the lock is renewed every ttl/3 and released when ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(ctx, name, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(l.o.retryInterval):
+		}
+	}
+}
+
+// TryAcquire acquire the lock if it is free, otherwise return ErrNotAcquired,
+// the lock is renewed every ttl/3 and released when ctx is done
+func (l *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	if name == "" {
+		return nil, errors.New("lock name cannot be empty")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("lock ttl must be positive")
+	}
+
+	lock := &Lock{
+		name:  name,
+		token: utils.UUIDv4(),
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+
+	var ok bool
+	var err error
+	if l.o.mode == ModeMySQL {
+		ok, err = l.tryMySQL(ctx, lock)
+	} else {
+		ok, err = l.tryLease(ctx, lock)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock '%s' error, err: %w", name, err)
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+
+	go lock.keep(ctx)
+	return lock, nil
+}
+
+func (l *Locker) tryLease(ctx context.Context, lock *Lock) (bool, error) {
+	db := l.db.WithContext(ctx)
+	now := time.Now()
+
+	// take over an expired lease
+	result := db.Model(&Lease{}).Where("name = ? AND expires_at < ?", lock.name, now).
+		Updates(map[string]interface{}{"owner": lock.token, "expires_at": now.Add(lock.ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		result = db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&Lease{Name: lock.name, Owner: lock.token, ExpiresAt: now.Add(lock.ttl)})
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return false, nil
+		}
+	}
+
+	lock.renew = func(ctx context.Context) (bool, error) {
+		result := l.db.WithContext(ctx).Model(&Lease{}).Where("name = ? AND owner = ?", lock.name, lock.token).
+			Update("expires_at", time.Now().Add(lock.ttl))
+		return result.RowsAffected == 1, result.Error
+	}
+	lock.release = func(ctx context.Context) error {
+		return l.db.WithContext(ctx).Where("name = ? AND owner = ?", lock.name, lock.token).Delete(&Lease{}).Error
+	}
+	return true, nil
+}
+
+func (l *Locker) tryMySQL(ctx context.Context, lock *Lock) (bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var got sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lock.name).Scan(&got); err != nil {
+		_ = conn.Close()
+		return false, err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		_ = conn.Close()
+		return false, nil
+	}
+
+	lock.renew = func(ctx context.Context) (bool, error) {
+		var owned sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", lock.name).Scan(&owned)
+		return owned.Valid && owned.Int64 == 1, err
+	}
+	lock.release = func(ctx context.Context) error {
+		defer conn.Close() //nolint
+		_, err := conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", lock.name)
+		return err
+	}
+	return true, nil
+}
+
+// Lock acquired named lock
+type Lock struct {
+	name  string
+	token string
+	ttl   time.Duration
+
+	renew   func(ctx context.Context) (bool, error)
+	release func(ctx context.Context) error
+
+	lost     chan struct{}
+	stop     chan struct{}
+	once     sync.Once
+	lostOnce sync.Once
+}
+
+// Name of the lock
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Token unique ownership token of this acquisition
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Lost is closed when the lock could not be renewed and may be held by another owner
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release the lock, calling it again has no effect
+func (l *Lock) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		err = l.release(ctx)
+	})
+	return err
+}
+
+// renew the lock until it is released, lost or ctx is done
+func (l *Lock) keep(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), l.ttl)
+			_ = l.Release(releaseCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			renewCtx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+			ok, err := l.renew(renewCtx)
+			cancel()
+
+			switch {
+			case err == nil && ok:
+				renewed = time.Now()
+			case err == nil || time.Since(renewed) >= l.ttl:
+				// taken over, or not renewed before the lease expired
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+		}
+	}
+}
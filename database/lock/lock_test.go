@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database/dbtest"
+)
+
+func TestLocker_TryAcquire(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Lease{})
+	locker := New(db)
+	assert.Equal(t, ModeLease, locker.o.mode)
+
+	l, err := locker.TryAcquire(ctx, "report", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "report", l.Name())
+	assert.NotEmpty(t, l.Token())
+
+	_, err = locker.TryAcquire(ctx, "report", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	other, err := locker.TryAcquire(ctx, "cleanup", time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, other.Release(ctx))
+
+	assert.NoError(t, l.Release(ctx))
+	assert.NoError(t, l.Release(ctx))
+	l, err = locker.TryAcquire(ctx, "report", time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Release(ctx))
+
+	_, err = locker.TryAcquire(ctx, "", time.Minute)
+	assert.Error(t, err)
+	_, err = locker.TryAcquire(ctx, "report", 0)
+	assert.Error(t, err)
+}
+
+func TestLocker_expired(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Lease{})
+	locker := New(db)
+
+	// a lease left behind by a crashed owner
+	err := db.Create(&Lease{Name: "report", Owner: "crashed", ExpiresAt: time.Now().Add(-time.Second)}).Error
+	assert.NoError(t, err)
+
+	l, err := locker.TryAcquire(ctx, "report", time.Minute)
+	assert.NoError(t, err)
+	lease := &Lease{}
+	assert.NoError(t, db.First(lease, "name = ?", "report").Error)
+	assert.Equal(t, l.Token(), lease.Owner)
+	assert.NoError(t, l.Release(ctx))
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	db := dbtest.New(t, &Lease{})
+	locker := New(db, WithRetryInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l, err := locker.Acquire(ctx, "report", time.Minute)
+	assert.NoError(t, err)
+
+	// waits until the first lock is released by cancelling its ctx
+	done := make(chan error)
+	go func() {
+		l2, err := locker.Acquire(context.Background(), "report", time.Minute)
+		if err == nil {
+			err = l2.Release(context.Background())
+		}
+		done <- err
+	}()
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	// gives up when ctx is done
+	l, err = locker.TryAcquire(context.Background(), "report", time.Minute)
+	assert.NoError(t, err)
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer waitCancel()
+	_, err = locker.Acquire(waitCtx, "report", time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, l.Release(context.Background()))
+}
+
+func TestLock_renew(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Lease{})
+	locker := New(db)
+
+	l, err := locker.TryAcquire(ctx, "report", 60*time.Millisecond)
+	assert.NoError(t, err)
+
+	// renewed past its first expiry
+	time.Sleep(100 * time.Millisecond)
+	_, err = locker.TryAcquire(ctx, "report", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	// taken away
+	assert.NoError(t, db.Model(&Lease{}).Where("name = ?", "report").Update("owner", "thief").Error)
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock not reported lost")
+	}
+	assert.NoError(t, l.Release(ctx))
+}
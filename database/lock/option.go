@@ -0,0 +1,49 @@
+package lock
+
+import "time"
+
+// lock implementations
+const (
+	// ModeAuto use ModeMySQL on mysql and ModeLease on other dialects
+	ModeAuto = "auto"
+	// ModeMySQL use GET_LOCK and RELEASE_LOCK, the lock is held by a dedicated connection
+	ModeMySQL = "mysql"
+	// ModeLease use rows of a lease table, works on any dialect
+	ModeLease = "lease"
+)
+
+// Option set the locker options.
+type Option func(*options)
+
+type options struct {
+	mode          string
+	retryInterval time.Duration
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	return &options{
+		mode:          ModeAuto,
+		retryInterval: 500 * time.Millisecond, // wait between attempts of Acquire
+	}
+}
+
+// WithMode set the lock implementation, ModeAuto by default
+func WithMode(mode string) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithRetryInterval set the wait between attempts while Acquire waits for a lock
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.retryInterval = d
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
 	"time"
 )
 
 var _ gormlogger.Interface = (*logger)(nil)
+var _ gorm.ParamsFilter = (*logger)(nil)
 
 type logger struct {
 	logger        *zap.Logger
 	slowThreshold time.Duration
+	explainer     *explainer
+	redactor      *redactor
+	parameterized bool // log the placeholders instead of the values
 }
 
 func (l *logger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
@@ -21,15 +26,26 @@ func (l *logger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
 }
 
 func (l *logger) Info(ctx context.Context, s string, i ...interface{}) {
-	l.logger.Info(fmt.Sprintf(s, i))
+	l.logger.Info(fmt.Sprintf(s, i...))
 }
 
 func (l *logger) Warn(ctx context.Context, s string, i ...interface{}) {
-	l.logger.Warn(fmt.Sprintf(s, i))
+	l.logger.Warn(fmt.Sprintf(s, i...))
 }
 
 func (l *logger) Error(ctx context.Context, s string, i ...interface{}) {
-	l.logger.Error(fmt.Sprintf(s, i))
+	l.logger.Error(fmt.Sprintf(s, i...))
+}
+
+// ParamsFilter mask the values before gorm interpolates them into the logged SQL
+func (l *logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.parameterized {
+		return sql, nil
+	}
+	if l.redactor != nil {
+		return sql, l.redactor.redact(sql, params)
+	}
+	return sql, params
 }
 
 func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
@@ -39,6 +55,7 @@ func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (sql stri
 	if l.slowThreshold != 0 && elapsed > l.slowThreshold {
 		l.logger.Warn("slow query",
 			zap.String("sql", sql),
+			zap.String("fingerprint", Fingerprint(sql)),
 			zap.Int64("rows", rows),
 			zap.Time("begin", begin),
 			zap.String("elapsed", elapsed.String()),
@@ -46,10 +63,14 @@ func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (sql stri
 		return
 	}
 
-	l.logger.Info("trace",
+	fields := []zap.Field{
 		zap.String("sql", sql),
 		zap.Int64("rows", rows),
 		zap.Time("begin", begin),
 		zap.String("elapsed", elapsed.String()),
-	)
+	}
+	if l.parameterized {
+		fields = append(fields, zap.String("fingerprint", Fingerprint(sql)))
+	}
+	l.logger.Info("trace", fields...)
 }
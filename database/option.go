@@ -2,6 +2,7 @@ package database
 
 import (
 	"go.uber.org/zap"
+	"regexp"
 	"time"
 )
 
@@ -17,6 +18,16 @@ type options struct {
 
 	disableForeignKey bool
 
+	explainFormat   string
+	explainInterval time.Duration
+
+	queryTimeout time.Duration
+	writeTimeout time.Duration
+
+	redactColumns    []string
+	redactPatterns   []*regexp.Regexp
+	parameterizedLog bool
+
 	logger *zap.Logger
 }
 
@@ -74,3 +85,55 @@ func WithLog(b bool, zlogger *zap.Logger, slowThreshold time.Duration) Option {
 		o.slowThreshold = slowThreshold
 	}
 }
+
+// WithSlowQueryExplain run EXPLAIN for slow SELECT statements and log the plan after the slow query entry, with the same fingerprint,
+// format is ExplainTraditional or ExplainJSON, each statement fingerprint is explained at most once per interval.
+// requires WithLog with a slow threshold.
+func WithSlowQueryExplain(format string, interval time.Duration) Option {
+	return func(o *options) {
+		o.explainFormat = format
+		o.explainInterval = interval
+	}
+}
+
+// WithQueryTimeout set the default timeout of SELECT statements whose context has no deadline,
+// a statement exceeding it is cancelled and returns an error matching ErrTimeout.
+// the deadline of Row and Rows also bounds the reading of their rows, an Exec of a SELECT gets this timeout
+func WithQueryTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.queryTimeout = d
+	}
+}
+
+// WithWriteTimeout set the default timeout of INSERT, UPDATE, DELETE and Exec statements whose context has no deadline,
+// a statement exceeding it is cancelled and returns an error matching ErrTimeout
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// WithRedactColumns mask the values bound to the columns in the logged SQL, example: WithRedactColumns("password", "phone"),
+// the columns of the model fields with the struct tag redact:"true" are masked as well.
+// the columns are matched by name in every table, the logged SQL does not tell the table of a value reliably,
+// so the tag on user.token masks the token column of the other tables too
+func WithRedactColumns(columns ...string) Option {
+	return func(o *options) {
+		o.redactColumns = append(o.redactColumns, columns...)
+	}
+}
+
+// WithRedactPatterns mask the parts of the bound string values matching the patterns in the logged SQL,
+// example: WithRedactPatterns(regexp.MustCompile(`1[3-9]\d{9}`))
+func WithRedactPatterns(patterns ...*regexp.Regexp) Option {
+	return func(o *options) {
+		o.redactPatterns = append(o.redactPatterns, patterns...)
+	}
+}
+
+// WithParameterizedLog log the statements with their placeholders instead of the bound values, with their fingerprint
+func WithParameterizedLog() Option {
+	return func(o *options) {
+		o.parameterizedLog = true
+	}
+}
@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xingmoo/library/utils"
+	"go.uber.org/zap"
+)
+
+// Option set the dispatcher options.
+type Option func(*options)
+
+type options struct {
+	batchSize    int
+	pollInterval time.Duration
+	lease        time.Duration
+	maxAttempts  int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	workerID     string
+
+	logger *zap.Logger
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default settings
+func defaultOptions() *options {
+	host, _ := os.Hostname()
+	return &options{
+		batchSize:    100,              // number of events claimed per poll
+		pollInterval: time.Second,      // wait between polls when the outbox is empty
+		lease:        30 * time.Second, // time a claimed event is reserved for this dispatcher
+		maxAttempts:  10,               // number of failed deliveries before an event is dead
+		minBackoff:   time.Second,
+		maxBackoff:   10 * time.Minute,
+		workerID:     fmt.Sprintf("%s-%d-%s", host, os.Getpid(), utils.UUIDv4()), // unique per dispatcher
+		logger:       zap.NewNop(),
+	}
+}
+
+// WithBatchSize set the number of events claimed per poll
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithPollInterval set the wait between polls when no event is pending
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithLease set the time a claimed event is reserved, the lease of the undelivered events of a batch is renewed
+// before each delivery, it must be longer than a delivery
+func WithLease(d time.Duration) Option {
+	return func(o *options) {
+		o.lease = d
+	}
+}
+
+// WithMaxAttempts set the number of failed deliveries after which an event is marked dead
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff set the bounds of the exponential retry delay
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(o *options) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithWorkerID set the id recorded on claimed events, it must be unique among the dispatchers,
+// default is hostname-pid followed by a random uuid
+func WithWorkerID(id string) Option {
+	return func(o *options) {
+		o.workerID = id
+	}
+}
+
+// WithLogger set the logger of delivery failures
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
@@ -0,0 +1,307 @@
+// Package outbox publishes events reliably through a table written in the same transaction as the business data.
+//
+// Append the event inside the transaction that changes the data, example:
+//
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		if err := tx.Model(order).Update("status", "paid").Error; err != nil {
+//			return err
+//		}
+//		return outbox.Append(ctx, tx, "order.paid", OrderPaid{ID: order.ID})
+//	})
+//
+// and run a dispatcher that delivers the committed events:
+//
+//	go outbox.NewDispatcher(db, publisher).Run(ctx)
+//
+// Delivery is at least once, an event may be published again if the dispatcher stops before marking it sent,
+// consumers should be idempotent, using Event.ID as the deduplication key.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xingmoo/library/database"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// event status
+const (
+	StatusPending = 0 // waiting for delivery
+	StatusSent    = 1 // delivered
+	StatusDead    = 2 // gave up after the maximum number of attempts
+)
+
+// Event row of the outbox table
+type Event struct {
+	ID            uint64     `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	Topic         string     `gorm:"column:topic;type:varchar(255);not null" json:"topic"`
+	Key           string     `gorm:"column:event_key;type:varchar(255)" json:"key"`
+	Payload       string     `gorm:"column:payload;type:text" json:"payload"`
+	Status        int        `gorm:"column:status;not null;default:0;index:idx_outbox_event_poll,priority:1" json:"status"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;index:idx_outbox_event_poll,priority:2" json:"next_attempt_at"`
+	Attempts      int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"column:last_error;type:text" json:"last_error"`
+	LockedBy      string     `gorm:"column:locked_by;type:varchar(255)" json:"-"`
+	LockedUntil   *time.Time `gorm:"column:locked_until" json:"-"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	SentAt        *time.Time `gorm:"column:sent_at" json:"sent_at"`
+}
+
+// TableName of the outbox
+func (Event) TableName() string {
+	return "outbox_event"
+}
+
+// Publisher deliver an event to a broker, an error schedules a retry
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc adapt a function to the Publisher interface
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+// Publish call f
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// AutoMigrate create or update the outbox table
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+// Append add an event to the outbox, tx should be the transaction that changes the data the event is about,
+// payload is stored as it is when it is a string or []byte, otherwise as json.
+// the optional key is passed to the publisher, example: a partition key
+func Append(ctx context.Context, tx *gorm.DB, topic string, payload interface{}, key ...string) error {
+	var data string
+	switch v := payload.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("outbox: marshal payload error, err: %w", err)
+		}
+		data = string(b)
+	}
+
+	event := &Event{
+		Topic:         topic,
+		Payload:       data,
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if len(key) > 0 {
+		event.Key = key[0]
+	}
+	return database.Create(ctx, tx, event)
+}
+
+// Dispatcher deliver the pending events of the outbox to a publisher
+type Dispatcher struct {
+	db        *gorm.DB
+	publisher Publisher
+	o         *options
+
+	now func() time.Time
+}
+
+// NewDispatcher create a dispatcher, several dispatchers may run on the same table
+func NewDispatcher(db *gorm.DB, publisher Publisher, opts ...Option) *Dispatcher {
+	o := defaultOptions()
+	o.apply(opts...)
+
+	return &Dispatcher{
+		db:        db,
+		publisher: publisher,
+		o:         o,
+		now:       time.Now,
+	}
+}
+
+// Run deliver events until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.o.logger.Error("outbox dispatch error", zap.Error(err))
+		}
+
+		// keep going while full batches are found
+		wait := d.o.pollInterval
+		if err == nil && n >= d.o.batchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// DispatchOnce claim a batch of due events and deliver them, return the number of claimed events
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	events, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := len(events)
+	for len(events) > 0 {
+		if ctx.Err() != nil {
+			// the lease of the remaining events expires and another dispatcher takes them
+			return n, ctx.Err()
+		}
+		if events, err = d.renew(ctx, events); err != nil {
+			return n, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		if err = d.deliver(ctx, events[0]); err != nil {
+			return n, err
+		}
+		events = events[1:]
+	}
+	return n, nil
+}
+
+// extend the lease of the undelivered events of the batch before each delivery, so that a slow publisher
+// does not let the lease of the last events expire, return the events still leased by this dispatcher
+func (d *Dispatcher) renew(ctx context.Context, events []*Event) ([]*Event, error) {
+	ids := make([]uint64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	until := d.now().Add(d.o.lease)
+	result := d.db.WithContext(ctx).Model(&Event{}).
+		Where("id IN ? AND locked_by = ?", ids, d.o.workerID).
+		Update("locked_until", until)
+	if result.Error != nil {
+		return nil, fmt.Errorf("outbox: renew lease error, err: %w", result.Error)
+	}
+	for _, event := range events {
+		event.LockedUntil = &until
+	}
+	if result.RowsAffected == int64(len(events)) {
+		return events, nil
+	}
+
+	// some leases expired and were taken over by another dispatcher
+	leased := []uint64{}
+	err := d.db.WithContext(ctx).Model(&Event{}).
+		Where("id IN ? AND locked_by = ?", ids, d.o.workerID).
+		Pluck("id", &leased).Error
+	if err != nil {
+		return nil, fmt.Errorf("outbox: renew lease error, err: %w", err)
+	}
+	owned := map[uint64]bool{}
+	for _, id := range leased {
+		owned[id] = true
+	}
+	kept := []*Event{}
+	for _, event := range events {
+		if owned[event.ID] {
+			kept = append(kept, event)
+		} else {
+			d.o.logger.Warn("outbox lease lost", zap.Uint64("id", event.ID), zap.String("topic", event.Topic))
+		}
+	}
+	return kept, nil
+}
+
+// reserve due events for this dispatcher by setting their lease,
+// on mysql the candidates are selected with FOR UPDATE SKIP LOCKED so concurrent dispatchers do not wait for each other
+func (d *Dispatcher) claim(ctx context.Context) ([]*Event, error) {
+	now := d.now()
+	until := now.Add(d.o.lease)
+	claimed := []*Event{}
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Order("id").Limit(d.o.batchSize)
+		if tx.Dialector.Name() == "mysql" {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		candidates := []*Event{}
+		if err := q.Find(&candidates).Error; err != nil {
+			return err
+		}
+
+		for _, event := range candidates {
+			result := tx.Model(&Event{}).
+				Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", event.ID, now).
+				Updates(map[string]interface{}{"locked_by": d.o.workerID, "locked_until": until})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 { // otherwise claimed by another dispatcher in the meantime
+				event.LockedBy, event.LockedUntil = d.o.workerID, &until
+				claimed = append(claimed, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outbox: claim events error, err: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, event *Event) error {
+	pubErr := d.publish(ctx, event)
+
+	update := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	if pubErr == nil {
+		update["status"] = StatusSent
+		update["sent_at"] = d.now()
+	} else {
+		attempts := event.Attempts + 1
+		update["attempts"] = attempts
+		update["last_error"] = pubErr.Error()
+		if attempts >= d.o.maxAttempts {
+			update["status"] = StatusDead
+			d.o.logger.Error("outbox event is dead", zap.Uint64("id", event.ID), zap.String("topic", event.Topic),
+				zap.Int("attempts", attempts), zap.Error(pubErr))
+		} else {
+			next := d.now().Add(database.Backoff(attempts, d.o.minBackoff, d.o.maxBackoff))
+			update["next_attempt_at"] = next
+			d.o.logger.Warn("outbox publish error", zap.Uint64("id", event.ID), zap.String("topic", event.Topic),
+				zap.Int("attempts", attempts), zap.Time("next_attempt_at", next), zap.Error(pubErr))
+		}
+	}
+
+	// only the owner of the lease may settle the event, an expired lease may have been taken over.
+	// the result is recorded even when ctx is done, so that a delivered event is not published again
+	err := d.db.WithContext(context.Background()).Model(&Event{}).
+		Where("id = ? AND locked_by = ?", event.ID, d.o.workerID).
+		Updates(update).Error
+	if err != nil {
+		return fmt.Errorf("outbox: update event %d error, err: %w", event.ID, err)
+	}
+	return nil
+}
+
+// a panicking publisher counts as a failed delivery
+func (d *Dispatcher) publish(ctx context.Context, event *Event) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("publisher panic: %v", e)
+		}
+	}()
+
+	return d.publisher.Publish(ctx, event)
+}
@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database/dbtest"
+	"gorm.io/gorm"
+)
+
+type orderPaid struct {
+	OrderID uint64 `json:"order_id"`
+}
+
+func TestAppend(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Event{})
+
+	// rolled back with the transaction
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		assert.NoError(t, Append(ctx, tx, "order.paid", orderPaid{OrderID: 1}))
+		return errors.New("rollback")
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Append(ctx, tx, "order.paid", orderPaid{OrderID: 2}, "order-2")
+	})
+	assert.NoError(t, err)
+
+	events := []Event{}
+	assert.NoError(t, db.Find(&events).Error)
+	assert.Len(t, events, 1)
+	assert.Equal(t, `{"order_id":2}`, events[0].Payload)
+	assert.Equal(t, "order-2", events[0].Key)
+	assert.Equal(t, StatusPending, events[0].Status)
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Event{})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, Append(ctx, db, "order.paid", orderPaid{OrderID: uint64(i)}))
+	}
+
+	published := []string{}
+	fail := true
+	d := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error {
+		if fail && event.Payload == `{"order_id":1}` {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, event.Payload)
+		return nil
+	}), WithMaxAttempts(2), WithBackoff(time.Minute, time.Hour))
+
+	n, err := d.DispatchOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{`{"order_id":0}`, `{"order_id":2}`}, published)
+
+	failed := &Event{}
+	assert.NoError(t, db.Where("payload = ?", `{"order_id":1}`).First(failed).Error)
+	assert.Equal(t, StatusPending, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "broker unavailable", failed.LastError)
+	assert.True(t, failed.NextAttemptAt.After(time.Now().Add(29*time.Second)))
+	assert.Nil(t, failed.LockedUntil)
+
+	// not due yet
+	n, err = d.DispatchOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// second failure, the event is dead
+	d.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	n, err = d.DispatchOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, db.First(failed, failed.ID).Error)
+	assert.Equal(t, StatusDead, failed.Status)
+
+	var sent int64
+	assert.NoError(t, db.Model(&Event{}).Where("status = ?", StatusSent).Count(&sent).Error)
+	assert.Equal(t, int64(2), sent)
+}
+
+func TestDispatcher_lease(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Event{})
+	assert.NoError(t, Append(ctx, db, "order.paid", "{}"))
+
+	d1 := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error { return nil }), WithWorkerID("d1"))
+	events, err := d1.claim(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// leased by d1
+	d2 := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error { return nil }), WithWorkerID("d2"))
+	events, err = d2.claim(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	// the lease expired
+	d2.now = func() time.Time { return time.Now().Add(time.Minute) }
+	events, err = d2.claim(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "d2", events[0].LockedBy)
+}
+
+func TestDispatcher_renew(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &Event{})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, Append(ctx, db, "order.paid", "{}"))
+	}
+
+	// each delivery takes 20s of a 30s lease, the lease of the last events is renewed before their delivery
+	now := time.Now()
+	clock := func() time.Time { return now }
+	d2 := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error { return nil }))
+	d2.now = clock
+	taken := 0
+	d1 := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error {
+		now = now.Add(20 * time.Second)
+		events, err := d2.claim(ctx)
+		taken += len(events)
+		return err
+	}), WithLease(30*time.Second))
+	d1.now = clock
+	assert.NotEqual(t, d1.o.workerID, d2.o.workerID)
+
+	n, err := d1.DispatchOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 0, taken)
+
+	// a lease taken over is not delivered again
+	assert.NoError(t, Append(ctx, db, "order.paid", "{}"))
+	events, err := d1.claim(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	now = now.Add(time.Minute)
+	_, err = d2.claim(ctx)
+	assert.NoError(t, err)
+	events, err = d1.renew(ctx, events)
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	db := dbtest.New(t, &Event{})
+	assert.NoError(t, Append(context.Background(), db, "order.paid", "{}"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	d := NewDispatcher(db, PublisherFunc(func(ctx context.Context, event *Event) error {
+		close(done)
+		return nil
+	}), WithPollInterval(10*time.Millisecond))
+
+	go func() {
+		<-done
+		cancel()
+	}()
+	err := d.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
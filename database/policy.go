@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrForbidden an update or delete targets rows that a policy does not allow,
+// use errors.Is(err, ErrForbidden) to check for it
+var ErrForbidden = errors.New("forbidden by policy")
+
+// ForbiddenError an update or delete was refused because it targets rows that a policy does not allow
+type ForbiddenError struct {
+	Table string
+	Op    string // update or delete
+	Rows  int64  // number of targeted rows not allowed by the policy
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("%s of %d rows of table %s is forbidden by policy", e.Op, e.Rows, e.Table)
+}
+
+// Is report whether target is ErrForbidden
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Policy return the condition the rows of model must satisfy in the statements run with ctx,
+// a nil condition allows all rows and an error fails the statement, example:
+//
+//	func ownedByUser(ctx context.Context, model interface{}) (clause.Expression, error) {
+//		user, ok := auth.FromContext(ctx)
+//		if !ok {
+//			return nil, errors.New("no user")
+//		}
+//		if user.Admin {
+//			return nil, nil
+//		}
+//		return gorm.Expr("owner_id = ?", user.ID), nil
+//	}
+type Policy func(ctx context.Context, model interface{}) (clause.Expression, error)
+
+// RegisterPolicy add a policy to a model in db, several policies of a model must all be satisfied.
+// the policies apply to every query, count, update and delete of the model run through gorm on db and its sessions,
+// including preloads, an update or delete targeting a row that is not allowed fails with a *ForbiddenError
+// and changes nothing. raw SQL is not covered.
+// db must be opened by Open or OpenDialector, which install the policies plugin, RegisterPolicy fails otherwise
+func RegisterPolicy(db *gorm.DB, model interface{}, policy Policy) error {
+	p, ok := db.Config.Plugins[policyPluginName].(*policyPlugin)
+	if !ok {
+		return errors.New("policy requires a db opened by database.Open or OpenDialector, it would not be enforced")
+	}
+
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policies[t] = append(p.policies[t], policy)
+	return nil
+}
+
+const policyPluginName = "database:policy"
+
+// the policies of the models of a db, enforced by its callbacks
+type policyPlugin struct {
+	mu       sync.RWMutex
+	policies map[reflect.Type][]Policy
+}
+
+func (p *policyPlugin) Name() string {
+	return policyPluginName
+}
+
+// register callbacks enforcing the policies of the models
+func (p *policyPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("library:policy", p.query); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("library:policy", p.query); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:setup_reflect_value").Before("gorm:update").Register("library:policy", p.write("update")); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("library:policy", p.write("delete"))
+}
+
+func registerPolicyCallbacks(db *gorm.DB) error {
+	return db.Use(&policyPlugin{policies: map[reflect.Type][]Policy{}})
+}
+
+type skipPoliciesKey struct{}
+
+// SkipPolicies return a context whose statements are not restricted by the policies, example: background jobs
+func SkipPolicies(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipPoliciesKey{}, true)
+}
+
+// the condition of the policies of the statement model, nil when unrestricted
+func (p *policyPlugin) condition(db *gorm.DB) (clause.Expression, error) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return nil, nil
+	}
+	if skip, _ := stmt.Context.Value(skipPoliciesKey{}).(bool); skip {
+		return nil, nil
+	}
+	p.mu.RLock()
+	list := p.policies[stmt.Schema.ModelType]
+	p.mu.RUnlock()
+
+	exprs := []clause.Expression{}
+	for _, policy := range list {
+		expr, err := policy(stmt.Context, stmt.Model)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			exprs = append(exprs, clause.Expr{SQL: "(?)", Vars: []interface{}{expr}})
+		}
+	}
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	return clause.And(exprs...), nil
+}
+
+// add the condition to the where clause, the existing conditions are grouped so that an OR cannot bypass it
+func restrict(stmt *gorm.Statement, condition clause.Expression) {
+	where := clause.Where{Exprs: []clause.Expression{condition}}
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			where.Exprs = []clause.Expression{clause.Expr{SQL: "(?)", Vars: []interface{}{clause.And(w.Exprs...)}}, condition}
+		}
+	}
+	c := stmt.Clauses["WHERE"]
+	c.Name = "WHERE"
+	c.Expression = where
+	stmt.Clauses["WHERE"] = c
+}
+
+func (p *policyPlugin) query(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	condition, err := p.condition(db)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if condition != nil {
+		restrict(db.Statement, condition)
+	}
+}
+
+// refuse the write when a targeted row is not allowed, otherwise restrict it to the allowed rows
+func (p *policyPlugin) write(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		condition, err := p.condition(db)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		if condition == nil {
+			return
+		}
+
+		stmt := db.Statement
+		where := targetConditions(db)
+		if len(where) == 0 && !db.AllowGlobalUpdate {
+			// refused by gorm for the missing where clause
+			restrict(stmt, condition)
+			return
+		}
+
+		var denied int64
+		check := db.Session(&gorm.Session{NewDB: true}).WithContext(SkipPolicies(stmt.Context)).
+			Model(reflect.New(stmt.Schema.ModelType).Interface())
+		check.Statement.AddClause(clause.Where{Exprs: where})
+		err = check.Where(clause.Expr{SQL: "(?) IS NOT TRUE", Vars: []interface{}{condition}}).Count(&denied).Error
+		if err != nil {
+			_ = db.AddError(fmt.Errorf("check policy error, err: %w", err))
+			return
+		}
+		if denied > 0 {
+			_ = db.AddError(&ForbiddenError{Table: stmt.Table, Op: op, Rows: denied})
+			return
+		}
+
+		restrict(stmt, condition)
+	}
+}
+
+// the conditions of the statement and the primary key of its model, which gorm adds when it builds the statement
+func targetConditions(db *gorm.DB) []clause.Expression {
+	stmt := db.Statement
+	exprs := []clause.Expression{}
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			exprs = append(exprs, clause.Expr{SQL: "(?)", Vars: []interface{}{clause.And(w.Exprs...)}})
+		}
+	}
+
+	rv := stmt.ReflectValue
+	if rv.Kind() == reflect.Struct && rv.Type() == stmt.Schema.ModelType {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if value, isZero := field.ValueOf(stmt.Context, rv); !isZero {
+				exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+			}
+		}
+	}
+	return exprs
+}
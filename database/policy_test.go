@@ -0,0 +1,102 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type noteExample struct {
+	database.Model `gorm:"embedded"`
+	OwnerID        uint64 `gorm:"column:owner_id" json:"owner_id"`
+	Title          string `gorm:"column:title" json:"title"`
+}
+
+type userKey struct{}
+
+type currentUser struct {
+	ID    uint64
+	Admin bool
+}
+
+func ownedByUser(ctx context.Context, model interface{}) (clause.Expression, error) {
+	user, ok := ctx.Value(userKey{}).(currentUser)
+	if !ok {
+		return nil, errors.New("no user")
+	}
+	if user.Admin {
+		return nil, nil
+	}
+	return gorm.Expr("owner_id = ?", user.ID), nil
+}
+
+func TestRegisterPolicy(t *testing.T) {
+	db := dbtest.New(t, &noteExample{})
+	assert.NoError(t, database.RegisterPolicy(db, &noteExample{}, ownedByUser))
+
+	system := database.SkipPolicies(context.Background())
+	notes := []*noteExample{{OwnerID: 1, Title: "a"}, {OwnerID: 1, Title: "b"}, {OwnerID: 2, Title: "c"}}
+	for _, note := range notes {
+		assert.NoError(t, database.Create(system, db, note))
+	}
+
+	alice := context.WithValue(context.Background(), userKey{}, currentUser{ID: 1})
+	admin := context.WithValue(context.Background(), userKey{}, currentUser{ID: 3, Admin: true})
+
+	// reads
+	list := []noteExample{}
+	err := database.List(alice, db, &list, query.NewPage(0, 10, "id"), "title = ? OR title = ?", "a", "c")
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	count, err := database.Count(alice, db, &noteExample{}, "id > ?", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = database.Count(admin, db, &noteExample{}, "id > ?", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	err = database.GetByID(alice, db, &noteExample{}, notes[2].ID)
+	assert.ErrorIs(t, err, query.ErrNotFound)
+	_, err = database.Count(context.Background(), db, &noteExample{}, "id > ?", 0)
+	assert.EqualError(t, err, "no user")
+
+	// writes
+	assert.NoError(t, database.Update(alice, db, &noteExample{}, "title", "a2", "id = ?", notes[0].ID))
+	err = database.Update(alice, db, &noteExample{}, "title", "c2", "id IN ?", []uint64{notes[1].ID, notes[2].ID})
+	assert.ErrorIs(t, err, database.ErrForbidden)
+	var forbidden *database.ForbiddenError
+	assert.True(t, errors.As(err, &forbidden))
+	assert.Equal(t, int64(1), forbidden.Rows)
+	err = db.WithContext(alice).Model(notes[2]).Update("title", "c2").Error
+	assert.ErrorIs(t, err, database.ErrForbidden)
+	err = database.DeleteByID(alice, db, &noteExample{}, notes[2].ID)
+	assert.ErrorIs(t, err, database.ErrForbidden)
+	assert.NoError(t, database.DeleteByID(admin, db, &noteExample{}, notes[2].ID))
+
+	// nothing was changed by the refused writes
+	got := &noteExample{}
+	assert.NoError(t, database.GetByID(system, db, got, notes[1].ID))
+	assert.Equal(t, "b", got.Title)
+	got = &noteExample{}
+	assert.NoError(t, database.GetByID(system, db, got, notes[0].ID))
+	assert.Equal(t, "a2", got.Title)
+
+	// the policies belong to db, the notes of another db are not restricted
+	other := dbtest.New(t, &noteExample{})
+	assert.NoError(t, database.Create(context.Background(), other, &noteExample{OwnerID: 2, Title: "d"}))
+	count, err = database.Count(alice, other, &noteExample{}, "id > ?", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// a db without the plugin would not enforce the policy
+	plain, err := gorm.Open(sqlite.Open(":memory:"))
+	assert.NoError(t, err)
+	assert.Error(t, database.RegisterPolicy(plain, &noteExample{}, ownedByUser))
+}
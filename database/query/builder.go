@@ -0,0 +1,176 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Cond a condition for server side code, built with Col, And, Or and Not, example:
+//
+//	cond := query.Where(query.Col("age").Gte(18), query.Or(query.Col("vip").Eq(true), query.Col("score").Gt(100)))
+//	err := database.List(ctx, db, &users, page, cond)
+//
+// a Cond is passed as the query argument of database.List, Count, Get, Update, Delete or gorm Where,
+// ToGormConditions returns the same SQL and args format as Params.ConvertToGormConditions
+type Cond struct {
+	sql   string
+	args  []interface{}
+	group bool // several conditions joined by AND or OR, wrapped in parentheses when combined
+	err   error
+}
+
+// ColumnExpr a column to compare, created by Col
+type ColumnExpr struct {
+	name string
+}
+
+// Col a column of the condition, the name may be qualified by the table, example: user.age
+func Col(name string) ColumnExpr {
+	return ColumnExpr{name: name}
+}
+
+func (c ColumnExpr) cond(sql string, args ...interface{}) Cond {
+	for _, segment := range strings.Split(c.name, ".") {
+		if !isIdentifier(segment) {
+			return Cond{err: fmt.Errorf("invalid column name '%s'", c.name)}
+		}
+	}
+	return Cond{sql: c.name + sql, args: args}
+}
+
+// Eq column = value
+func (c ColumnExpr) Eq(value interface{}) Cond {
+	return c.cond(expMap[Eq]+"?", value)
+}
+
+// Neq column <> value
+func (c ColumnExpr) Neq(value interface{}) Cond {
+	return c.cond(expMap[Neq]+"?", value)
+}
+
+// Gt column > value
+func (c ColumnExpr) Gt(value interface{}) Cond {
+	return c.cond(expMap[Gt]+"?", value)
+}
+
+// Gte column >= value
+func (c ColumnExpr) Gte(value interface{}) Cond {
+	return c.cond(expMap[Gte]+"?", value)
+}
+
+// Lt column < value
+func (c ColumnExpr) Lt(value interface{}) Cond {
+	return c.cond(expMap[Lt]+"?", value)
+}
+
+// Lte column <= value
+func (c ColumnExpr) Lte(value interface{}) Cond {
+	return c.cond(expMap[Lte]+"?", value)
+}
+
+// Like column contains value, as the like expression of Params
+func (c ColumnExpr) Like(value interface{}) Cond {
+	return c.cond(expMap[Like]+"?", fmt.Sprintf("%%%v%%", value))
+}
+
+// In column is one of the values
+func (c ColumnExpr) In(values ...interface{}) Cond {
+	return c.cond(" IN (?)", values)
+}
+
+// NotIn column is none of the values
+func (c ColumnExpr) NotIn(values ...interface{}) Cond {
+	return c.cond(" NOT IN (?)", values)
+}
+
+// IsNull column IS NULL
+func (c ColumnExpr) IsNull() Cond {
+	return c.cond(" IS NULL")
+}
+
+// IsNotNull column IS NOT NULL
+func (c ColumnExpr) IsNotNull() Cond {
+	return c.cond(" IS NOT NULL")
+}
+
+// Where all the conditions, the same as And
+func Where(conds ...Cond) Cond {
+	return And(conds...)
+}
+
+// And all the conditions
+func And(conds ...Cond) Cond {
+	return join(expAnd, conds)
+}
+
+// Or any of the conditions
+func Or(conds ...Cond) Cond {
+	return join(expOr, conds)
+}
+
+// Not the condition is false
+func Not(cond Cond) Cond {
+	if cond.err != nil || cond.sql == "" {
+		return cond
+	}
+	return Cond{sql: "NOT (" + cond.sql + ")", args: cond.args}
+}
+
+var (
+	expAnd = logicMap[AND]
+	expOr  = logicMap[OR]
+)
+
+func join(logic string, conds []Cond) Cond {
+	parts := []string{}
+	args := []interface{}{}
+	var single Cond
+	for _, cond := range conds {
+		if cond.err != nil {
+			return cond
+		}
+		if cond.sql == "" {
+			continue
+		}
+		single = cond
+		sql := cond.sql
+		if cond.group {
+			sql = "(" + sql + ")"
+		}
+		parts = append(parts, sql)
+		args = append(args, cond.args...)
+	}
+
+	switch len(parts) {
+	case 0:
+		return Cond{}
+	case 1:
+		return single
+	}
+	return Cond{sql: strings.Join(parts, logic), args: args, group: true}
+}
+
+// ToGormConditions the SQL and args of the condition, an empty SQL when there is no condition
+func (c Cond) ToGormConditions() (string, []interface{}, error) {
+	return c.sql, c.args, c.err
+}
+
+// Build write the condition to a gorm statement, an empty condition matches all rows
+func (c Cond) Build(builder clause.Builder) {
+	if c.err != nil {
+		_ = builder.AddError(c.err)
+		return
+	}
+	if c.sql == "" {
+		_, _ = builder.WriteString("1 = 1")
+		return
+	}
+
+	sql := c.sql
+	if c.group {
+		sql = "(" + sql + ")"
+	}
+	clause.Expr{SQL: sql, Vars: c.args}.Build(builder)
+}
@@ -0,0 +1,95 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name string
+		cond Cond
+		sql  string
+		args []interface{}
+	}{
+		{
+			name: "one",
+			cond: Where(Col("age").Gte(18)),
+			sql:  "age >= ?",
+			args: []interface{}{18},
+		},
+		{
+			name: "nested",
+			cond: Where(Col("age").Gte(18), Or(Col("vip").Eq(true), Col("score").Gt(100)), Col("user.name").Like("li")),
+			sql:  "age >= ? AND (vip = ? OR score > ?) AND user.name LIKE ?",
+			args: []interface{}{18, true, 100, "%li%"},
+		},
+		{
+			name: "in and not",
+			cond: And(Col("id").In(1, 2), Not(Or(Col("deleted_at").IsNotNull(), Col("status").Neq(1))), Col("role").NotIn("guest")),
+			sql:  "id IN (?) AND NOT (deleted_at IS NOT NULL OR status <> ?) AND role NOT IN (?)",
+			args: []interface{}{[]interface{}{1, 2}, 1, []interface{}{"guest"}},
+		},
+		{
+			name: "empty groups",
+			cond: Where(Or(), And(Col("age").Lt(60)), Col("age").Lte(30)),
+			sql:  "age < ? AND age <= ?",
+			args: []interface{}{60, 30},
+		},
+		{
+			name: "empty",
+			cond: Where(),
+			sql:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := tt.cond.ToGormConditions()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+
+	_, _, err := Where(Col("age").Gt(1), Or(Col("name; DROP TABLE user").Eq(1))).ToGormConditions()
+	assert.EqualError(t, err, "invalid column name 'name; DROP TABLE user'")
+}
+
+func TestWhere_sameAsParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *Params
+		cond   Cond
+	}{
+		{
+			name: "and",
+			params: &Params{Columns: []Column{
+				{Name: "name", Value: "li", Exp: Like},
+				{Name: "age", Value: 18, Exp: Gte},
+				{Name: "gender", Value: "male"},
+			}},
+			cond: Where(Col("name").Like("li"), Col("age").Gte(18), Col("gender").Eq("male")),
+		},
+		{
+			name: "in",
+			params: &Params{Columns: []Column{
+				{Name: "id", Value: 1, Logic: OR},
+				{Name: "id", Value: 2},
+			}},
+			cond: Col("id").In(1, 2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, wantArgs, err := tt.params.ConvertToGormConditions()
+			assert.NoError(t, err)
+			got, args, err := tt.cond.ToGormConditions()
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.Equal(t, wantArgs, args)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-module/carbon/v2"
+)
+
+// DatePrefix marks a relative date value of the comparisons other than between, example: date:today, date:-30d,
+// the values of the Params.DateColumns do not need it
+const DatePrefix = "date:"
+
+// the clock of the relative dates, replaced by the tests
+var timeNow = time.Now
+
+var (
+	lastDaysRegexp   = regexp.MustCompile(`^last_(\d+)_days$`)
+	dateOffsetRegexp = regexp.MustCompile(`^([+-]\d+)([smhdwMy])$`)
+)
+
+// a range of dates from start included to end excluded, or a point in time when start equals end
+type dateRange struct {
+	start time.Time
+	end   time.Time
+}
+
+func (r dateRange) isPoint() bool {
+	return r.start.Equal(r.end)
+}
+
+// parseRelativeDate resolve a relative date of a column value in the timezone, the local timezone by default.
+// the relative dates are
+//
+//	now, an offset from now: -30d, +2h (s, m, h, d, w, M and y)
+//	today, yesterday, tomorrow, this_week, last_week, this_month, last_month, this_year, last_year, the weeks start on monday
+//	last_N_days: the N days ending with today, example: last_7_days
+//	a range of two relative dates or dates separated by .., example: -30d..now, 2026-01-01..today
+//
+// ok is false when the value is not a relative date
+func parseRelativeDate(value string, timezone string) (r dateRange, ok bool, err error) {
+	value = strings.TrimSpace(value)
+	from, to, isRange := strings.Cut(value, "..")
+	if !isRange {
+		if _, ok = relativeDate(value, carbon.NewCarbon()); !ok {
+			return dateRange{}, false, nil
+		}
+	}
+
+	now := carbon.FromStdTime(timeNow()).SetWeekStartsAt(carbon.Monday)
+	if timezone != "" {
+		now = now.SetTimezone(timezone)
+	}
+	if now.Error != nil {
+		return dateRange{}, false, fmt.Errorf("invalid timezone '%s'", timezone)
+	}
+
+	if !isRange {
+		r, _ = relativeDate(value, now)
+		return r, true, nil
+	}
+
+	start, ok := dateBound(from, now)
+	if !ok {
+		return dateRange{}, false, nil
+	}
+	end, ok := dateBound(to, now)
+	if !ok {
+		return dateRange{}, false, nil
+	}
+	r = dateRange{start: start.start, end: end.end}
+	if r.end.Before(r.start) {
+		return dateRange{}, false, fmt.Errorf("date range '%s' ends before it starts", value)
+	}
+	return r, true, nil
+}
+
+// a bound of a range, a relative date or a date in the timezone of now, a date without time is the whole day
+func dateBound(value string, now carbon.Carbon) (dateRange, bool) {
+	value = strings.TrimSpace(value)
+	if r, ok := relativeDate(value, now); ok {
+		return r, true
+	}
+
+	c := carbon.Parse(value, now.Location())
+	if c.Error != nil || c.IsZero() {
+		return dateRange{}, false
+	}
+	if strings.ContainsAny(value, ":T") {
+		return dateRange{start: c.ToStdTime(), end: c.ToStdTime()}, true
+	}
+	return dateRange{start: c.StartOfDay().ToStdTime(), end: c.StartOfDay().AddDay().ToStdTime()}, true
+}
+
+func relativeDate(value string, now carbon.Carbon) (dateRange, bool) {
+	period := func(start carbon.Carbon, end carbon.Carbon) (dateRange, bool) {
+		return dateRange{start: start.ToStdTime(), end: end.ToStdTime()}, true
+	}
+	today := now.StartOfDay()
+	week := now.StartOfWeek()
+	month := now.StartOfMonth()
+	year := now.StartOfYear()
+
+	switch strings.ToLower(value) {
+	case "now":
+		return period(now, now)
+	case "today":
+		return period(today, today.AddDay())
+	case "yesterday":
+		return period(today.SubDay(), today)
+	case "tomorrow":
+		return period(today.AddDay(), today.AddDays(2))
+	case "this_week":
+		return period(week, week.AddWeek())
+	case "last_week":
+		return period(week.SubWeek(), week)
+	case "this_month":
+		return period(month, month.AddMonth())
+	case "last_month":
+		return period(month.SubMonth(), month)
+	case "this_year":
+		return period(year, year.AddYear())
+	case "last_year":
+		return period(year.SubYear(), year)
+	}
+
+	if m := lastDaysRegexp.FindStringSubmatch(strings.ToLower(value)); m != nil {
+		days, err := strconv.Atoi(m[1])
+		if err != nil || days < 1 {
+			return dateRange{}, false
+		}
+		return period(today.SubDays(days-1), today.AddDay())
+	}
+
+	if m := dateOffsetRegexp.FindStringSubmatch(value); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return dateRange{}, false
+		}
+		var t carbon.Carbon
+		switch m[2] {
+		case "s":
+			t = now.AddSeconds(n)
+		case "m":
+			t = now.AddMinutes(n)
+		case "h":
+			t = now.AddHours(n)
+		case "d":
+			t = now.AddDays(n)
+		case "w":
+			t = now.AddWeeks(n)
+		case "M":
+			t = now.AddMonthsNoOverflow(n)
+		default:
+			t = now.AddYearsNoOverflow(n)
+		}
+		return period(t, t)
+	}
+
+	return dateRange{}, false
+}
+
+// the condition of a relative date value, a range is compared with its start and end,
+// example: date:today is created_at >= ? AND created_at < ?. the values are only read as relative dates
+// with the between expression, the DatePrefix or in a date column, so that a search for the text today is not a date,
+// ok is false when the value is not a relative date
+func (c *Column) dateCondition(dialect string, timezone string) (condition string, args []interface{}, ok bool, err error) {
+	value, isString := c.Value.(string)
+	prefixed := isString && strings.HasPrefix(value, DatePrefix)
+	if c.Exp != Between && !prefixed && !(c.date && isString) {
+		return "", nil, false, nil
+	}
+
+	var r dateRange
+	if isString {
+		r, ok, err = parseRelativeDate(strings.TrimPrefix(value, DatePrefix), timezone)
+		if err != nil {
+			return "", nil, false, err
+		}
+	}
+	if !ok && c.Exp != Between && !prefixed {
+		return "", nil, false, nil // a date of a date column, example: 2026-01-01
+	}
+	if !ok || (c.Exp == Between && r.isPoint()) {
+		if c.Exp == Between {
+			return "", nil, false, fmt.Errorf("invalid date range '%v' of column '%s'", c.Value, c.Name)
+		}
+		return "", nil, false, fmt.Errorf("invalid relative date '%v' of column '%s'", c.Value, c.Name)
+	}
+
+	name, err := c.expression(dialect)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if r.isPoint() {
+		return name + c.Exp + "?", []interface{}{r.start}, true, nil
+	}
+	switch c.Exp {
+	case " <> ":
+		return "(" + name + " < ? OR " + name + " >= ?)", []interface{}{r.start, r.end}, true, nil
+	case " > ":
+		return name + " >= ?", []interface{}{r.end}, true, nil
+	case " >= ":
+		return name + " >= ?", []interface{}{r.start}, true, nil
+	case " < ":
+		return name + " < ?", []interface{}{r.start}, true, nil
+	case " <= ":
+		return name + " < ?", []interface{}{r.end}, true, nil
+	case " = ", Between:
+		return "(" + name + " >= ? AND " + name + " < ?)", []interface{}{r.start, r.end}, true, nil
+	}
+	return "", nil, false, nil
+}
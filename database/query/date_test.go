@@ -0,0 +1,180 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParams_relativeDate(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// wednesday 2026-03-04 01:30 in Shanghai, still tuesday in UTC
+	now := time.Date(2026, 3, 3, 17, 30, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, shanghai)
+	}
+
+	tests := []struct {
+		name  string
+		exp   string
+		value interface{}
+		want  string
+		args  []interface{}
+	}{
+		{
+			name:  "today",
+			value: "date:today",
+			want:  "(created_at >= ? AND created_at < ?)",
+			args:  []interface{}{day(2026, 3, 4), day(2026, 3, 5)},
+		},
+		{
+			name:  "yesterday",
+			exp:   Neq,
+			value: "date:Yesterday",
+			want:  "(created_at < ? OR created_at >= ?)",
+			args:  []interface{}{day(2026, 3, 3), day(2026, 3, 4)},
+		},
+		{
+			name:  "this week",
+			exp:   Gte,
+			value: "date:this_week",
+			want:  "created_at >= ?",
+			args:  []interface{}{day(2026, 3, 2)},
+		},
+		{
+			name:  "last month",
+			exp:   Lte,
+			value: "date:last_month",
+			want:  "created_at < ?",
+			args:  []interface{}{day(2026, 3, 1)},
+		},
+		{
+			name:  "last 7 days",
+			exp:   Between,
+			value: "last_7_days",
+			want:  "(created_at >= ? AND created_at < ?)",
+			args:  []interface{}{day(2026, 2, 26), day(2026, 3, 5)},
+		},
+		{
+			name:  "offset range",
+			exp:   Between,
+			value: "-30d..now",
+			want:  "(created_at >= ? AND created_at < ?)",
+			args:  []interface{}{now.AddDate(0, 0, -30).In(shanghai), now.In(shanghai)},
+		},
+		{
+			name:  "date range",
+			exp:   Between,
+			value: "2026-01-01..yesterday",
+			want:  "(created_at >= ? AND created_at < ?)",
+			args:  []interface{}{day(2026, 1, 1), day(2026, 3, 4)},
+		},
+		{
+			name:  "point",
+			exp:   Lt,
+			value: "date:-2h",
+			want:  "created_at < ?",
+			args:  []interface{}{now.Add(-2 * time.Hour).In(shanghai)},
+		},
+		{
+			name:  "text",
+			value: "today",
+			want:  "created_at = ?",
+			args:  []interface{}{"today"},
+		},
+		{
+			name:  "not a date",
+			value: "2026-01-01",
+			want:  "created_at = ?",
+			args:  []interface{}{"2026-01-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &Params{Timezone: "Asia/Shanghai", Columns: []Column{{Name: "created_at", Exp: tt.exp, Value: tt.value}}}
+			got, args, err := params.ConvertToGormConditions()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			require.Len(t, args, len(tt.args))
+			for i := range args {
+				assert.Equal(t, tt.args[i], args[i])
+			}
+		})
+	}
+}
+
+func TestParams_relativeDate_error(t *testing.T) {
+	columns := []Column{{Name: "created_at", Value: "date:today"}}
+	_, _, err := (&Params{Timezone: "Mars/Olympus", Columns: columns}).ConvertToGormConditions()
+	assert.EqualError(t, err, "invalid timezone 'Mars/Olympus'")
+
+	columns = []Column{{Name: "created_at", Exp: Gte, Value: "date:someday"}}
+	_, _, err = (&Params{Columns: columns}).ConvertToGormConditions()
+	assert.EqualError(t, err, "invalid relative date 'date:someday' of column 'created_at'")
+
+	columns = []Column{{Name: "created_at", Exp: Between, Value: "now"}}
+	_, _, err = (&Params{Columns: columns}).ConvertToGormConditions()
+	assert.EqualError(t, err, "invalid date range 'now' of column 'created_at'")
+
+	columns = []Column{{Name: "created_at", Exp: Between, Value: "today..-30d"}}
+	_, _, err = (&Params{Columns: columns}).ConvertToGormConditions()
+	assert.EqualError(t, err, "date range 'today..-30d' ends before it starts")
+}
+
+func TestParseFilter_relativeDate(t *testing.T) {
+	columns, err := ParseFilter(`status = 1 and created_at between "last_7_days"`)
+	require.NoError(t, err)
+	got, args, err := (&Params{Columns: columns}).ConvertToGormConditions()
+	require.NoError(t, err)
+	assert.Equal(t, "status = ? AND (created_at >= ? AND created_at < ?)", got)
+	assert.Len(t, args, 3)
+
+	// the same column with two ranges is not an IN
+	columns = []Column{{Name: "created_at", Value: "date:today", Logic: OR}, {Name: "created_at", Value: "date:yesterday"}}
+	got, _, err = (&Params{Columns: columns}).ConvertToGormConditions()
+	require.NoError(t, err)
+	assert.Equal(t, "(created_at >= ? AND created_at < ?) OR (created_at >= ? AND created_at < ?)", got)
+}
+
+func TestParams_relativeDate_text(t *testing.T) {
+	// a search for the text today is not a date
+	for _, value := range []string{"today", "now", "-1d", "last_7_days"} {
+		got, args, err := (&Params{Columns: []Column{{Name: "title", Value: value}}}).ConvertToGormConditions()
+		require.NoError(t, err)
+		assert.Equal(t, "title = ?", got)
+		assert.Equal(t, []interface{}{value}, args)
+	}
+}
+
+func TestParams_relativeDate_dateColumns(t *testing.T) {
+	now := time.Date(2026, 3, 3, 17, 30, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+	today := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	params := &Params{Timezone: "UTC", DateColumns: []string{"created_at"}, Columns: []Column{
+		{Name: "created_at", Exp: Gte, Value: "today"},
+		{Name: "created_at", Exp: Lt, Value: "-30d..now"},
+		{Name: "title", Value: "today"},
+	}}
+	got, args, err := params.ConvertToGormConditions()
+	require.NoError(t, err)
+	assert.Equal(t, "created_at >= ? AND created_at < ? AND title = ?", got)
+	require.Len(t, args, 3)
+	assert.True(t, today.Equal(args[0].(time.Time)))
+	assert.True(t, now.AddDate(0, 0, -30).Equal(args[1].(time.Time)))
+	assert.Equal(t, "today", args[2])
+
+	// a date of a date column is compared as it is
+	params.Columns = []Column{{Name: "created_at", Exp: Gte, Value: "2026-01-01"}}
+	got, args, err = params.ConvertToGormConditions()
+	require.NoError(t, err)
+	assert.Equal(t, "created_at >= ?", got)
+	assert.Equal(t, []interface{}{"2026-01-01"}, args)
+}
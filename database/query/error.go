@@ -1,8 +1,157 @@
 package query
 
-import "gorm.io/gorm"
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
 
 var (
 	// ErrNotFound record
 	ErrNotFound = gorm.ErrRecordNotFound
+
+	// ErrDuplicateKey a unique key or primary key already holds the value, the same as gorm.ErrDuplicatedKey
+	ErrDuplicateKey = gorm.ErrDuplicatedKey
+	// ErrForeignKey a foreign key constraint fails, the referenced row is missing or still referenced
+	ErrForeignKey = errors.New("foreign key constraint fails")
+	// ErrDeadlock the transaction was rolled back to resolve a deadlock
+	ErrDeadlock = errors.New("deadlock found when trying to get lock")
+	// ErrLockWaitTimeout the statement waited too long for a row lock
+	ErrLockWaitTimeout = errors.New("lock wait timeout exceeded")
+	// ErrDataTooLong a value is longer than its column
+	ErrDataTooLong = errors.New("data too long for column")
+	// ErrConnectionLost the connection to the server was lost
+	ErrConnectionLost = errors.New("connection lost")
+)
+
+// mysql error numbers
+const (
+	erDupEntry              = 1062
+	erDupEntryWithKeyName   = 1586
+	erNoReferencedRow       = 1216
+	erRowIsReferenced       = 1217
+	erRowIsReferenced2      = 1451
+	erNoReferencedRow2      = 1452
+	erLockDeadlock          = 1213
+	erLockWaitTimeout       = 1205
+	erDataTooLong           = 1406
+	erServerShutdown        = 1053
+	erConnectionKilled      = 1927
+	erClientInteractionTime = 4031
+	crServerGone            = 2006
+	crServerLost            = 2013
 )
+
+// DBError a classified driver error, use errors.Is with the sentinel errors to check its kind, example:
+//
+//	var dbErr *query.DBError
+//	if errors.As(err, &dbErr) && errors.Is(err, query.ErrDuplicateKey) {
+//		return fmt.Errorf("%s is already used", dbErr.Constraint)
+//	}
+type DBError struct {
+	Kind       error  // one of the sentinel errors
+	Number     uint16 // mysql error number, 0 when the error does not come from the server
+	Constraint string // name of the violated key or foreign key, when reported by the server
+	Column     string // column of ErrDataTooLong
+	Err        error  // driver error
+}
+
+func (e *DBError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap return the driver error
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether target is the kind of the error
+func (e *DBError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// ConvertError classify a mysql driver error as a *DBError, other errors are returned unchanged,
+// the errors of the functions of the database package are converted already
+func ConvertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		e := &DBError{Number: mysqlErr.Number, Err: err}
+		switch mysqlErr.Number {
+		case erDupEntry, erDupEntryWithKeyName:
+			e.Kind = ErrDuplicateKey
+			e.Constraint = duplicateKeyName(mysqlErr.Message)
+		case erNoReferencedRow, erRowIsReferenced, erRowIsReferenced2, erNoReferencedRow2:
+			e.Kind = ErrForeignKey
+			e.Constraint = between(mysqlErr.Message, "CONSTRAINT `", "`")
+		case erLockDeadlock:
+			e.Kind = ErrDeadlock
+		case erLockWaitTimeout:
+			e.Kind = ErrLockWaitTimeout
+		case erDataTooLong:
+			e.Kind = ErrDataTooLong
+			e.Column = between(mysqlErr.Message, "column '", "'")
+		case erServerShutdown, erConnectionKilled, erClientInteractionTime, crServerGone, crServerLost:
+			e.Kind = ErrConnectionLost
+		default:
+			return err
+		}
+		return e
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || isConnectionIOError(err) {
+		return &DBError{Kind: ErrConnectionLost, Err: err}
+	}
+	return err
+}
+
+// a read or write on an established connection failed, a failed dial or lookup is not a lost connection
+func isConnectionIOError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "read" || opErr.Op == "write")
+}
+
+// IsRetryable report whether the failed statement or transaction may succeed when run again:
+// a deadlock, a lock wait timeout or a lost connection.
+// after a lost connection the outcome of a commit is unknown, retry only idempotent work
+func IsRetryable(err error) bool {
+	err = ConvertError(err)
+	return errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockWaitTimeout) || errors.Is(err, ErrConnectionLost)
+}
+
+// the key of "Duplicate entry 'x' for key 'user.uk_email'", mysql 8 prefixes it with the table name
+func duplicateKeyName(message string) string {
+	i := strings.LastIndex(message, "for key '")
+	if i < 0 {
+		return ""
+	}
+	name := strings.TrimSuffix(message[i+len("for key '"):], "'")
+	if j := strings.LastIndexByte(name, '.'); j >= 0 {
+		name = name[j+1:]
+	}
+	return name
+}
+
+// the text between the first prefix and the following suffix
+func between(s string, prefix string, suffix string) string {
+	i := strings.Index(s, prefix)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(prefix):]
+	if j := strings.Index(s, suffix); j >= 0 {
+		return s[:j]
+	}
+	return ""
+}
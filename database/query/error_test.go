@@ -0,0 +1,115 @@
+package query
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestConvertError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		kind       error
+		constraint string
+		column     string
+		retryable  bool
+	}{
+		{
+			name:       "duplicate key mysql 8",
+			err:        &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'user.uk_email'"},
+			kind:       ErrDuplicateKey,
+			constraint: "uk_email",
+		},
+		{
+			name:       "duplicate key mysql 5.7",
+			err:        &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"},
+			kind:       ErrDuplicateKey,
+			constraint: "PRIMARY",
+		},
+		{
+			name: "foreign key",
+			err: &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails " +
+				"(`shop`.`order`, CONSTRAINT `fk_order_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))"},
+			kind:       ErrForeignKey,
+			constraint: "fk_order_user",
+		},
+		{
+			name:      "deadlock",
+			err:       &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"},
+			kind:      ErrDeadlock,
+			retryable: true,
+		},
+		{
+			name:      "lock wait timeout",
+			err:       &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"},
+			kind:      ErrLockWaitTimeout,
+			retryable: true,
+		},
+		{
+			name:   "data too long",
+			err:    &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"},
+			kind:   ErrDataTooLong,
+			column: "name",
+		},
+		{
+			name:      "server gone",
+			err:       &mysql.MySQLError{Number: 2006, Message: "MySQL server has gone away"},
+			kind:      ErrConnectionLost,
+			retryable: true,
+		},
+		{
+			name:      "invalid connection",
+			err:       fmt.Errorf("exec error: %w", mysql.ErrInvalidConn),
+			kind:      ErrConnectionLost,
+			retryable: true,
+		},
+		{
+			name:      "bad connection",
+			err:       driver.ErrBadConn,
+			kind:      ErrConnectionLost,
+			retryable: true,
+		},
+		{
+			name:      "connection reset",
+			err:       &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")},
+			kind:      ErrConnectionLost,
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConvertError(tt.err)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err.Error(), err.Error())
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err))
+
+			var dbErr *DBError
+			assert.True(t, errors.As(err, &dbErr))
+			assert.Equal(t, tt.constraint, dbErr.Constraint)
+			assert.Equal(t, tt.column, dbErr.Column)
+			assert.Same(t, err, ConvertError(err))
+		})
+	}
+
+	assert.ErrorIs(t, ConvertError(&mysql.MySQLError{Number: 1062}), gorm.ErrDuplicatedKey)
+
+	other := &mysql.MySQLError{Number: 1146, Message: "Table 'shop.foo' doesn't exist"}
+	assert.Same(t, other, ConvertError(other))
+	assert.Equal(t, ErrNotFound, ConvertError(ErrNotFound))
+	assert.NoError(t, ConvertError(nil))
+	assert.False(t, IsRetryable(ErrNotFound))
+
+	// a failed dial or lookup is not a lost connection
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "db"}}
+	assert.Same(t, dial, ConvertError(dial))
+	assert.False(t, IsRetryable(dial))
+}
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConvertToFields validate the Fields parameter against the columns that may be selected,
+// each element may hold several columns separated by a comma, example: fields=id,title
+func (p *Params) ConvertToFields(allowed []string) ([]string, error) {
+	columns := map[string]bool{}
+	for _, column := range allowed {
+		columns[column] = true
+	}
+
+	fields := []string{}
+	seen := map[string]bool{}
+	for _, field := range p.Fields {
+		for _, name := range strings.Split(field, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			if !columns[name] {
+				return nil, fmt.Errorf("field '%s' cannot be selected", name)
+			}
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// CheckColumns validate the names of the Columns and Filter parameters against the columns that may be queried,
+// the json column of a path is validated, example: attrs.color may be queried when attrs is allowed
+func (p *Params) CheckColumns(allowed []string) error {
+	if strings.TrimSpace(p.Filter) != "" {
+		if _, err := ParseFilter(p.Filter, allowed...); err != nil {
+			return err
+		}
+	}
+
+	columns := map[string]bool{}
+	for _, column := range allowed {
+		columns[column] = true
+	}
+
+	for _, c := range p.Columns {
+		if strings.ToLower(c.Exp) == Match {
+			names, err := matchColumns(c.Name)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if !columns[name] {
+					return fmt.Errorf("column '%s' cannot be queried", name)
+				}
+			}
+			continue
+		}
+
+		path, isJSON, err := parseJSONPath(c.Name)
+		if err != nil {
+			return err
+		}
+		name := c.Name
+		if isJSON {
+			name = path.column
+		} else if first, _, ok := strings.Cut(name, "."); ok && !columns[name] && p.isJSONColumn(first) {
+			name = first // a path inside a json column, example: attrs.color
+		}
+		if !columns[name] {
+			return fmt.Errorf("column '%s' cannot be queried", c.Name)
+		}
+	}
+	return nil
+}
+
+// SortColumns column names of the sort fields
+func (p *Page) SortColumns() []string {
+	columns := []string{}
+	for _, item := range strings.Split(p.sort, ",") {
+		if fields := strings.Fields(item); len(fields) > 0 {
+			columns = append(columns, fields[0])
+		}
+	}
+	return columns
+}
+
+// report whether column may hold json, any column when the JSONColumns are not set
+func (p *Params) isJSONColumn(column string) bool {
+	if p.JSONColumns == nil {
+		return true
+	}
+	for _, c := range p.JSONColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// report whether the values of column may be relative dates without the DatePrefix
+func (p *Params) isDateColumn(column string) bool {
+	for _, c := range p.DateColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams_ConvertToFields(t *testing.T) {
+	allowed := []string{"id", "title", "body", "created_at"}
+
+	p := &Params{Fields: []string{"title, id", "title"}}
+	fields, err := p.ConvertToFields(allowed)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title", "id"}, fields)
+
+	p = &Params{Fields: []string{"title,password"}}
+	_, err = p.ConvertToFields(allowed)
+	assert.EqualError(t, err, "field 'password' cannot be selected")
+
+	p = &Params{Fields: []string{"id,(SELECT 1)"}}
+	_, err = p.ConvertToFields(allowed)
+	assert.Error(t, err)
+}
+
+func TestPage_SortColumns(t *testing.T) {
+	assert.Equal(t, []string{"name", "age"}, NewPage(0, 10, "-name,age").SortColumns())
+	assert.Equal(t, []string{"id"}, DefaultPage(0).SortColumns())
+}
+
+func TestParams_CheckColumns(t *testing.T) {
+	allowed := []string{"id", "name", "attrs"}
+
+	p := &Params{Columns: []Column{{Name: "name"}, {Name: "attrs.color"}, {Name: "attrs->$.size"}}}
+	assert.NoError(t, p.CheckColumns(allowed))
+
+	p = &Params{Columns: []Column{{Name: "password"}}}
+	assert.EqualError(t, p.CheckColumns(allowed), "column 'password' cannot be queried")
+
+	p = &Params{Columns: []Column{{Name: "secret.color"}}}
+	assert.EqualError(t, p.CheckColumns(allowed), "column 'secret.color' cannot be queried")
+
+	p = &Params{Columns: []Column{{Name: "attrs.color')"}}}
+	assert.Error(t, p.CheckColumns(allowed))
+}
@@ -0,0 +1,510 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilterColumns the maximum number of columns a filter expands to, a filter is expanded to an OR of ANDs,
+// example: (a or b) and (c or d) expands to a and c or a and d or b and c or b and d
+const maxFilterColumns = 200
+
+// FilterError a syntax error of a filter, or a column that cannot be queried
+type FilterError struct {
+	Pos int // position of the error in the filter, in characters starting at 1
+	Msg string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter error at position %d: %s", e.Pos, e.Msg)
+}
+
+// ParseFilter parse a one line filter into the columns of Params, the columns are validated
+// against allowed when it is not empty. syntax:
+//
+//	status in (1,2) and (name ~ "bob" or created_at >= "2026-01-01")
+//
+// the operators are = (or ==), != (or <>), >, >=, <, <=, ~ (or like), contains, match, between, in and not in,
+// conditions are combined with and (&&), or (||) and parentheses, and takes precedence over or.
+// values are numbers, true, false and strings quoted with " or ', a dotted column name is a json path, example: attrs.color,
+// dates may be relative, example: created_at between "-30d..now" or updated_at = "date:today"
+func ParseFilter(filter string, allowed ...string) ([]Column, error) {
+	p := &filterParser{input: filter}
+	if len(allowed) > 0 {
+		p.allowed = map[string]bool{}
+		for _, column := range allowed {
+			p.allowed[column] = true
+		}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, nil
+	}
+
+	groups, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	columns := flatten(groups)
+	if len(columns) > maxFilterColumns {
+		return nil, &FilterError{Pos: 1, Msg: fmt.Sprintf("filter is too complex, it expands to more than %d conditions", maxFilterColumns)}
+	}
+	return columns, nil
+}
+
+// the columns of the Columns and Filter parameters combined with AND
+func (p *Params) conditions() ([]Column, error) {
+	columns := p.jsonPaths()
+	if strings.TrimSpace(p.Filter) == "" {
+		return columns, nil
+	}
+	filter, err := ParseFilter(p.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return filter, nil
+	}
+
+	groups, err := and(groupsOf(columns), groupsOf(filter))
+	if err != nil {
+		return nil, err
+	}
+	return flatten(groups), nil
+}
+
+// the filter is compiled to groups of columns, the columns of a group are combined with AND and the groups with OR
+type filterGroups [][]Column
+
+// split flat columns at their OR logic
+func groupsOf(columns []Column) filterGroups {
+	groups := filterGroups{}
+	group := []Column{}
+	for i, column := range columns {
+		logic := strings.ToLower(column.Logic)
+		column.Logic = AND
+		group = append(group, column)
+		if i == len(columns)-1 || logic == OR || logic == "|" || logic == "||" {
+			groups = append(groups, group)
+			group = []Column{}
+		}
+	}
+	return groups
+}
+
+// the columns understood by ConvertToGormConditions, which relies on AND taking precedence over OR
+func flatten(groups filterGroups) []Column {
+	groups = notIN(groups)
+	columns := []Column{}
+	for _, group := range groups {
+		for i, column := range group {
+			column.Logic = AND
+			if i == len(group)-1 {
+				column.Logic = OR
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// ConvertToGormConditions turns columns that all compare one column with = into an IN, which is only right when
+// every group has one column, otherwise the first = of a larger group is written as >= AND <= to keep its AND
+func notIN(groups filterGroups) filterGroups {
+	if len(groups) == 0 || len(groups[0]) == 0 {
+		return groups
+	}
+	name, count, grouped := groups[0][0].Name, 0, -1
+	for i, group := range groups {
+		for _, column := range group {
+			if column.Name != name || !isEq(column) {
+				return groups
+			}
+			count++
+		}
+		if len(group) > 1 && grouped < 0 {
+			grouped = i
+		}
+	}
+	if count < 2 || grouped < 0 {
+		return groups
+	}
+
+	out := append(filterGroups{}, groups...)
+	group := out[grouped]
+	gte, lte := group[0], group[0]
+	gte.Exp, lte.Exp = Gte, Lte
+	out[grouped] = append([]Column{gte, lte}, group[1:]...)
+	return out
+}
+
+// the column compares a single value with =
+func isEq(column Column) bool {
+	if column.Exp != "" && expMap[strings.ToLower(column.Exp)] != expMap[Eq] {
+		return false
+	}
+	s, ok := column.Value.(string)
+	return !ok || !strings.HasPrefix(s, DatePrefix) // a relative date is a range
+}
+
+func and(a filterGroups, b filterGroups) (filterGroups, error) {
+	groups := filterGroups{}
+	size := 0
+	for _, x := range a {
+		for _, y := range b {
+			group := append(append([]Column{}, x...), y...)
+			if size += len(group); size > maxFilterColumns {
+				return nil, fmt.Errorf("filter is too complex, it expands to more than %d conditions", maxFilterColumns)
+			}
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // in characters starting at 1
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return "string " + strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+func (t token) is(keyword string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, keyword)
+}
+
+type filterParser struct {
+	input   string
+	offset  int // in bytes
+	tok     token
+	allowed map[string]bool
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return &FilterError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr expr := and { (or | ||) and }
+func (p *filterParser) parseOr() (filterGroups, error) {
+	groups, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.is(OR) || (p.tok.kind == tokOperator && p.tok.text == "||") {
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, right...)
+	}
+	return groups, nil
+}
+
+// parseAnd and := factor { (and | &&) factor }
+func (p *filterParser) parseAnd() (filterGroups, error) {
+	groups, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.is(AND) || (p.tok.kind == tokOperator && p.tok.text == "&&") {
+		pos := p.tok.pos
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		if groups, err = and(groups, right); err != nil {
+			return nil, &FilterError{Pos: pos, Msg: err.Error()}
+		}
+	}
+	return groups, nil
+}
+
+// parseFactor factor := ( expr ) | comparison
+func (p *filterParser) parseFactor() (filterGroups, error) {
+	if p.tok.kind != tokLParen {
+		return p.parseComparison()
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	groups, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokRParen {
+		return nil, p.errorf("expected ')', found %s", p.tok)
+	}
+	return groups, p.next()
+}
+
+var filterOperators = map[string]string{
+	"=": Eq, "==": Eq, "!=": Neq, "<>": Neq, ">": Gt, ">=": Gte, "<": Lt, "<=": Lte, "~": Like,
+	Like: Like, Contains: Contains, Match: Match, Between: Between,
+}
+
+// parseComparison comparison := column operator value | column [not] in ( value {, value} )
+func (p *filterParser) parseComparison() (filterGroups, error) {
+	if p.tok.kind != tokIdent || isFilterKeyword(p.tok.text) {
+		return nil, p.errorf("expected a column name, found %s", p.tok)
+	}
+	name := p.tok.text
+	if err := p.checkColumn(name); err != nil {
+		return nil, err
+	}
+	name = arrowPath(name)
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	not := false
+	if p.tok.is("not") {
+		not = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !p.tok.is("in") {
+			return nil, p.errorf("expected 'in' after 'not', found %s", p.tok)
+		}
+	}
+	if p.tok.is("in") {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if not { // one group of column != value
+			group := []Column{}
+			for _, value := range values {
+				group = append(group, Column{Name: name, Exp: Neq, Value: value, Logic: AND})
+			}
+			return filterGroups{group}, nil
+		}
+		groups := filterGroups{}
+		for _, value := range values {
+			groups = append(groups, []Column{{Name: name, Exp: Eq, Value: value, Logic: AND}})
+		}
+		return groups, nil
+	}
+
+	exp, ok := "", false
+	if p.tok.kind == tokOperator || p.tok.kind == tokIdent {
+		exp, ok = filterOperators[strings.ToLower(p.tok.text)]
+	}
+	if !ok {
+		return nil, p.errorf("expected an operator after column '%s', found %s", name, p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return filterGroups{{{Name: name, Exp: exp, Value: value, Logic: AND}}}, nil
+}
+
+// the values of in ( value {, value} )
+func (p *filterParser) parseList() ([]interface{}, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokLParen {
+		return nil, p.errorf("expected '(' after 'in', found %s", p.tok)
+	}
+	values := []interface{}{}
+	for {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		switch p.tok.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return values, p.next()
+		}
+		return nil, p.errorf("expected ',' or ')', found %s", p.tok)
+	}
+}
+
+func (p *filterParser) parseValue() (interface{}, error) {
+	var value interface{}
+	switch {
+	case p.tok.kind == tokString:
+		value = p.tok.text
+	case p.tok.kind == tokNumber:
+		if n, err := strconv.ParseInt(p.tok.text, 10, 64); err == nil {
+			value = n
+		} else if f, err := strconv.ParseFloat(p.tok.text, 64); err == nil {
+			value = f
+		} else {
+			return nil, p.errorf("invalid number %s", p.tok)
+		}
+	case p.tok.is("true"):
+		value = true
+	case p.tok.is("false"):
+		value = false
+	default:
+		return nil, p.errorf("expected a value, found %s, quote the strings", p.tok)
+	}
+	return value, p.next()
+}
+
+func (p *filterParser) checkColumn(name string) error {
+	path, isJSON, err := parsePath(name, true)
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	column := name
+	if isJSON {
+		column = path.column
+	} else if !isIdentifier(name) {
+		return p.errorf("invalid column name '%s'", name)
+	}
+	if p.allowed != nil && !p.allowed[column] {
+		return p.errorf("column '%s' cannot be queried", name)
+	}
+	return nil
+}
+
+func isFilterKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case AND, OR, "not", "in", Like, Contains, Match, Between, "true", "false":
+		return true
+	}
+	return false
+}
+
+// next read the next token
+func (p *filterParser) next() error {
+	s := p.input
+	for p.offset < len(s) {
+		r, size := utf8.DecodeRuneInString(s[p.offset:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.offset += size
+	}
+
+	start := p.offset
+	pos := utf8.RuneCountInString(s[:start]) + 1
+	if start >= len(s) {
+		p.tok = token{kind: tokEOF, pos: pos}
+		return nil
+	}
+
+	c := s[start]
+	switch {
+	case c == '(':
+		p.tok = token{kind: tokLParen, text: "(", pos: pos}
+		p.offset++
+	case c == ')':
+		p.tok = token{kind: tokRParen, text: ")", pos: pos}
+		p.offset++
+	case c == ',':
+		p.tok = token{kind: tokComma, text: ",", pos: pos}
+		p.offset++
+	case c == '"' || c == '\'':
+		text, end, ok := readQuoted(s, start)
+		if !ok {
+			return &FilterError{Pos: pos, Msg: "unterminated string"}
+		}
+		p.tok = token{kind: tokString, text: text, pos: pos}
+		p.offset = end
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		end := start + 1
+		for end < len(s) && (isDigitOrDot(s[end]) || ((s[end] == '-' || s[end] == '+') && (s[end-1] == 'e' || s[end-1] == 'E')) ||
+			s[end] == 'e' || s[end] == 'E') {
+			end++
+		}
+		p.tok = token{kind: tokNumber, text: s[start:end], pos: pos}
+		p.offset = end
+	case c == '_' || isLetter(c):
+		end := start + 1
+		for end < len(s) && (s[end] == '_' || s[end] == '.' || s[end] == '[' || s[end] == ']' || isLetter(s[end]) || isDigitOrDot(s[end])) {
+			end++
+		}
+		p.tok = token{kind: tokIdent, text: s[start:end], pos: pos}
+		p.offset = end
+	default:
+		for _, op := range []string{"==", "!=", "<>", ">=", "<=", "&&", "||", "=", ">", "<", "~"} {
+			if strings.HasPrefix(s[start:], op) {
+				p.tok = token{kind: tokOperator, text: op, pos: pos}
+				p.offset += len(op)
+				return nil
+			}
+		}
+		r, _ := utf8.DecodeRuneInString(s[start:])
+		return &FilterError{Pos: pos, Msg: fmt.Sprintf("unexpected character '%c'", r)}
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigitOrDot(c byte) bool {
+	return c == '.' || (c >= '0' && c <= '9')
+}
+
+// the unquoted string starting at the quote at start, and the offset after its closing quote,
+// a backslash escapes the next character
+func readQuoted(s string, start int) (string, int, bool) {
+	quote := s[start]
+	b := strings.Builder{}
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case quote:
+			return b.String(), i + 1, true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, false
+}
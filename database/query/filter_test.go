@@ -0,0 +1,128 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+		args   []interface{}
+	}{
+		{
+			name:   "in",
+			filter: "status in (1, 2)",
+			want:   "status IN (?)",
+			args:   []interface{}{[]interface{}{int64(1), int64(2)}},
+		},
+		{
+			name:   "group",
+			filter: `status in (1,2) and (name ~ "bob" or created_at >= "2026-01-01")`,
+			want:   "status = ? AND name LIKE ? OR status = ? AND created_at >= ? OR status = ? AND name LIKE ? OR status = ? AND created_at >= ?",
+			args:   []interface{}{int64(1), "%bob%", int64(1), "2026-01-01", int64(2), "%bob%", int64(2), "2026-01-01"},
+		},
+		{
+			name:   "precedence",
+			filter: "a = 1 || b != 'x' && c <> 2.5",
+			want:   "a = ? OR b <> ? AND c <> ?",
+			args:   []interface{}{int64(1), "x", 2.5},
+		},
+		{
+			name:   "not in",
+			filter: `vip = true AND role NOT IN ("guest", 'bot') and attrs.color == "red"`,
+			want:   "vip = ? AND role <> ? AND role <> ? AND attrs->>'$.color' = ?",
+			args:   []interface{}{true, "guest", "bot", "red"},
+		},
+		{
+			name:   "escape",
+			filter: `name like "say \"hi\"" or age<=-3`,
+			want:   "name LIKE ? OR age <= ?",
+			args:   []interface{}{`%say "hi"%`, int64(-3)},
+		},
+		{
+			name:   "same column",
+			filter: "(status = 1 or status = 2) and status = 3",
+			want:   "status >= ? AND status <= ? AND status = ? OR status = ? AND status = ?",
+			args:   []interface{}{int64(1), int64(1), int64(3), int64(2), int64(3)},
+		},
+		{
+			name:   "same column and",
+			filter: "status = 1 and status = 3",
+			want:   "status >= ? AND status <= ? AND status = ?",
+			args:   []interface{}{int64(1), int64(1), int64(3)},
+		},
+		{
+			name:   "same column or",
+			filter: "status = 1 or status = 2",
+			want:   "status IN (?)",
+			args:   []interface{}{[]interface{}{int64(1), int64(2)}},
+		},
+		{
+			name:   "empty",
+			filter: "  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			got, args, err := (&Params{Columns: columns}).ConvertToGormConditions()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestParseFilter_error(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`name = "bob`, "filter error at position 8: unterminated string"},
+		{`name = bob`, "filter error at position 8: expected a value, found 'bob', quote the strings"},
+		{`name "bob"`, `filter error at position 6: expected an operator after column 'name', found string "bob"`},
+		{`(a = 1 or b = 2`, "filter error at position 16: expected ')', found end of filter"},
+		{`a = 1 and`, "filter error at position 10: expected a column name, found end of filter"},
+		{`a = 1 b = 2`, "filter error at position 7: unexpected 'b'"},
+		{`a in 1`, "filter error at position 6: expected '(' after 'in', found '1'"},
+		{`a not = 1`, "filter error at position 7: expected 'in' after 'not', found '='"},
+		{`a = 1 ; drop`, "filter error at position 7: unexpected character ';'"},
+		{`secret = 1`, "filter error at position 1: column 'secret' cannot be queried"},
+		{`name = 1 or attrs[0 = 2`, "filter error at position 13: invalid json path of column 'attrs[0'"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.filter, "name", "a", "b", "attrs")
+		assert.EqualError(t, err, tt.want, tt.filter)
+		var filterErr *FilterError
+		assert.ErrorAs(t, err, &filterErr)
+	}
+
+	_, err := ParseFilter("(a in (1,2,3,4,5,6,7,8,9,10)) and (b in (1,2,3,4,5,6,7,8,9,10)) and (c in (1,2,3))")
+	assert.ErrorContains(t, err, "filter is too complex")
+}
+
+func TestParams_Filter(t *testing.T) {
+	p := &Params{
+		Columns: []Column{{Name: "a", Value: 1, Logic: OR}, {Name: "b", Value: 2}},
+		Filter:  "c = 3 or d = 4",
+	}
+	got, args, err := p.ConvertToGormConditions()
+	assert.NoError(t, err)
+	assert.Equal(t, "a = ? AND c = ? OR a = ? AND d = ? OR b = ? AND c = ? OR b = ? AND d = ?", got)
+	assert.Equal(t, []interface{}{1, int64(3), 1, int64(4), 2, int64(3), 2, int64(4)}, args)
+
+	assert.NoError(t, p.CheckColumns([]string{"a", "b", "c", "d"}))
+	assert.EqualError(t, p.CheckColumns([]string{"a", "b", "c"}), "filter error at position 10: column 'd' cannot be queried")
+
+	p.Filter = "c = "
+	_, _, err = p.ConvertToGormConditions()
+	assert.EqualError(t, err, "filter error at position 5: expected a value, found end of filter, quote the strings")
+}
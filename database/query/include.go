@@ -0,0 +1,111 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/huandu/xstrings"
+)
+
+var relations sync.Map // model type -> map[string]Relation
+
+// Relation a relation of a model that clients may include,
+// the name is the path used in the include parameter, example: comments.user
+type Relation struct {
+	Name  string        // relation path in the include parameter, segments separated by '.'
+	Field string        // gorm preload path, default is the camel case of Name, example: Comments.User
+	Where string        // optional condition of the related rows, example: "status = ?"
+	Args  []interface{} // arguments of Where
+	Order string        // optional order of the related rows, example: "id DESC"
+	Limit int           // optional maximum number of related rows of each parent, for a has many relation, needs window functions (mysql 8)
+}
+
+// AllowIncludes register the relations of a model that clients may include, example:
+//
+//	query.AllowIncludes(&Article{},
+//		query.Relation{Name: "author"},
+//		query.Relation{Name: "comments", Where: "status = ?", Args: []interface{}{1}, Order: "id DESC"},
+//		query.Relation{Name: "comments.user"},
+//	)
+func AllowIncludes(model interface{}, rels ...Relation) {
+	t := modelType(model)
+	allowed := map[string]Relation{}
+	if v, ok := relations.Load(t); ok {
+		for name, rel := range v.(map[string]Relation) {
+			allowed[name] = rel
+		}
+	}
+
+	for _, rel := range rels {
+		if rel.Field == "" {
+			segments := strings.Split(rel.Name, ".")
+			for i, segment := range segments {
+				segments[i] = xstrings.ToCamelCase(segment)
+			}
+			rel.Field = strings.Join(segments, ".")
+		}
+		allowed[rel.Name] = rel
+	}
+	relations.Store(t, allowed)
+}
+
+// ConvertToIncludes validate the Include parameter against the relations allowed for model,
+// each element may hold several relations separated by a comma, example: include=author,comments.user,
+// the allowed parents of a nested relation are added before it
+func (p *Params) ConvertToIncludes(model interface{}) ([]Relation, error) {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, include := range p.Include {
+		for _, name := range strings.Split(include, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	allowed := map[string]Relation{}
+	if v, ok := relations.Load(modelType(model)); ok {
+		allowed = v.(map[string]Relation)
+	}
+
+	rels := make([]Relation, 0, len(names))
+	added := map[string]bool{}
+	for _, name := range names {
+		rel, ok := allowed[name]
+		if !ok {
+			return nil, fmt.Errorf("relation '%s' cannot be included", name)
+		}
+
+		// the parents of a nested relation are preloaded with their own conditions
+		segments := strings.Split(name, ".")
+		for i := 1; i < len(segments); i++ {
+			parent := strings.Join(segments[:i], ".")
+			if prel, ok := allowed[parent]; ok && !added[parent] {
+				added[parent] = true
+				rels = append(rels, prel)
+			}
+		}
+		if !added[name] {
+			added[name] = true
+			rels = append(rels, rel)
+		}
+	}
+	return rels, nil
+}
+
+// struct type of a model, a pointer, slice or pointer to slice of it
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	return t
+}
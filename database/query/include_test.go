@@ -0,0 +1,41 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type articleExample struct {
+	ID uint64
+}
+
+func TestParams_ConvertToIncludes(t *testing.T) {
+	AllowIncludes(&articleExample{},
+		Relation{Name: "author"},
+		Relation{Name: "comments", Order: "id DESC", Limit: 10},
+	)
+	AllowIncludes(articleExample{}, Relation{Name: "comments.user_profile"})
+
+	p := &Params{Include: []string{"author, comments.user_profile", "author"}}
+	rels, err := p.ConvertToIncludes(&[]articleExample{})
+	assert.NoError(t, err)
+	assert.Len(t, rels, 3)
+	assert.Equal(t, "Author", rels[0].Field)
+	assert.Equal(t, "Comments", rels[1].Field)
+	assert.Equal(t, "Comments.UserProfile", rels[2].Field)
+
+	p = &Params{Include: []string{"comments"}}
+	rels, err = p.ConvertToIncludes([]*articleExample{})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, rels[0].Limit)
+
+	p = &Params{Include: []string{"author,password_resets"}}
+	_, err = p.ConvertToIncludes(&articleExample{})
+	assert.EqualError(t, err, "relation 'password_resets' cannot be included")
+
+	p = &Params{}
+	rels, err = p.ConvertToIncludes(&articleExample{})
+	assert.NoError(t, err)
+	assert.Nil(t, rels)
+}
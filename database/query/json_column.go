@@ -0,0 +1,195 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// database dialects of the json expressions, the names of the gorm dialectors
+const (
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// jsonPath a path inside a json column, example: attrs.color or attrs->$.size
+type jsonPath struct {
+	column   string
+	segments []string // keys and array indexes, example: [sizes 0] for $.sizes[0]
+}
+
+// parseJSONPath split a column name into the json column and the path inside it,
+// the path is written as attrs->$.color, attrs->>'$.size', attrs.sizes[0] or attrs.sizes.0,
+// a name of identifiers separated by dots is a table qualified column, example: user.name,
+// the Params write the dotted names of their json columns as attrs->$.color before, see Params.jsonPaths,
+// ok is false when the name has no path
+func parseJSONPath(name string) (p jsonPath, ok bool, err error) {
+	return parsePath(name, false)
+}
+
+// dotted reads a name of identifiers separated by dots as a json path instead of a qualified column
+func parsePath(name string, dotted bool) (p jsonPath, ok bool, err error) {
+	var path string
+	if i := strings.Index(name, "->"); i >= 0 {
+		p.column = strings.TrimSpace(name[:i])
+		path = strings.TrimPrefix(name[i+2:], ">")
+		path = strings.Trim(strings.TrimSpace(path), "'\"")
+		if !strings.HasPrefix(path, "$") {
+			return p, false, fmt.Errorf("json path of column '%s' must start with '$'", name)
+		}
+		path = path[1:]
+	} else if !dotted && isQualifiedName(name) {
+		return p, false, nil
+	} else if i := strings.IndexAny(name, ".["); i >= 0 {
+		p.column = name[:i]
+		path = name[i:]
+	} else {
+		return p, false, nil
+	}
+
+	if !isIdentifier(p.column) {
+		return p, false, fmt.Errorf("invalid json column '%s'", name)
+	}
+	for path != "" {
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			key := path[1:end]
+			switch {
+			case isIdentifier(key):
+				p.segments = append(p.segments, key)
+			case isIndex(key): // attrs.sizes.0 is the same as attrs.sizes[0]
+				p.segments = append(p.segments, "["+key+"]")
+			default:
+				return p, false, fmt.Errorf("invalid json path of column '%s'", name)
+			}
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 || !isIndex(path[1:end]) {
+				return p, false, fmt.Errorf("invalid json path of column '%s'", name)
+			}
+			p.segments = append(p.segments, path[:end+1])
+			path = path[end+1:]
+		default:
+			return p, false, fmt.Errorf("invalid json path of column '%s'", name)
+		}
+	}
+	return p, true, nil
+}
+
+// mysql and sqlite path, example: $.sizes[0]
+func (p jsonPath) path() string {
+	path := "$"
+	for _, segment := range p.segments {
+		if strings.HasPrefix(segment, "[") {
+			path += segment
+		} else {
+			path += "." + segment
+		}
+	}
+	return path
+}
+
+// postgres path, example: {sizes,0}
+func (p jsonPath) pgPath() string {
+	segments := make([]string, 0, len(p.segments))
+	for _, segment := range p.segments {
+		segments = append(segments, strings.Trim(segment, "[]"))
+	}
+	return "{" + strings.Join(segments, ",") + "}"
+}
+
+// expression of the value at the path, unquoted so that it compares with plain values
+func (p jsonPath) extract(dialect string) string {
+	switch dialect {
+	case DialectSQLite:
+		return fmt.Sprintf("json_extract(%s, '%s')", p.column, p.path())
+	case DialectPostgres:
+		return fmt.Sprintf("%s #>> '%s'", p.column, p.pgPath())
+	default:
+		return fmt.Sprintf("%s->>'%s'", p.column, p.path())
+	}
+}
+
+// condition that the json at the path contains value, an element of an array or the value itself
+func (p jsonPath) contains(dialect string, value interface{}) (string, interface{}, error) {
+	switch dialect {
+	case DialectSQLite:
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s, '%s') WHERE json_each.value = ?)", p.column, p.path()), value, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal value of column '%s' error, err: %w", p.column, err)
+	}
+	if dialect == DialectPostgres {
+		return fmt.Sprintf("%s #> '%s' @> ?::jsonb", p.column, p.pgPath()), string(data), nil
+	}
+	return fmt.Sprintf("JSON_CONTAINS(%s, ?, '%s')", p.column, p.path()), string(data), nil
+}
+
+// a dotted name is a json path, example: attrs.color is attrs->$.color
+func arrowPath(name string) string {
+	if strings.Contains(name, "->") {
+		return name
+	}
+	if i := strings.IndexAny(name, ".["); i > 0 {
+		return name[:i] + "->$" + name[i:]
+	}
+	return name
+}
+
+// the Columns with their dotted names written as json paths, example: attrs.color is attrs->$.color,
+// when the JSONColumns are set only the names starting with one of them, user.name stays a qualified column
+func (p *Params) jsonPaths() []Column {
+	columns := make([]Column, len(p.Columns))
+	for i, c := range p.Columns {
+		first, _, dotted := strings.Cut(c.Name, ".")
+		if dotted && p.isJSONColumn(first) && strings.ToLower(c.Exp) != Match {
+			if _, ok, err := parsePath(c.Name, true); ok && err == nil {
+				c.Name = arrowPath(c.Name)
+			}
+		}
+		columns[i] = c
+	}
+	return columns
+}
+
+// identifiers separated by dots, example: user.name
+func isQualifiedName(s string) bool {
+	for _, segment := range strings.Split(s, ".") {
+		if !isIdentifier(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams_ConvertToGormConditions_json(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []Column
+		dialect string
+		want    string
+		args    []interface{}
+	}{
+		{
+			name:    "mysql path",
+			columns: []Column{{Name: "attrs.color", Value: "red"}, {Name: "attrs->$.size", Exp: Gte, Value: 40}},
+			dialect: DialectMySQL,
+			want:    "attrs->>'$.color' = ? AND attrs->>'$.size' >= ?",
+			args:    []interface{}{"red", 40},
+		},
+		{
+			name:    "default dialect",
+			columns: []Column{{Name: "attrs->>'$.sizes[0]'", Value: 40}},
+			want:    "attrs->>'$.sizes[0]' = ?",
+			args:    []interface{}{40},
+		},
+		{
+			name:    "sqlite path",
+			columns: []Column{{Name: "attrs.sizes.0", Value: 40}, {Name: "attrs.color", Exp: Like, Value: "re"}},
+			dialect: DialectSQLite,
+			want:    "json_extract(attrs, '$.sizes[0]') = ? AND json_extract(attrs, '$.color') LIKE ?",
+			args:    []interface{}{40, "%re%"},
+		},
+		{
+			name:    "postgres path",
+			columns: []Column{{Name: "attrs.sizes[0]", Exp: Lt, Value: 40}},
+			dialect: DialectPostgres,
+			want:    "attrs #>> '{sizes,0}' < ?",
+			args:    []interface{}{40},
+		},
+		{
+			name:    "in",
+			columns: []Column{{Name: "attrs.color", Value: "red", Logic: OR}, {Name: "attrs.color", Value: "blue"}},
+			want:    "attrs->>'$.color' IN (?)",
+			args:    []interface{}{[]interface{}{"red", "blue"}},
+		},
+		{
+			name:    "mysql contains",
+			columns: []Column{{Name: "attrs.tags", Exp: Contains, Value: "sale"}, {Name: "labels", Exp: Contains, Value: 1}},
+			want:    "JSON_CONTAINS(attrs, ?, '$.tags') AND JSON_CONTAINS(labels, ?, '$')",
+			args:    []interface{}{`"sale"`, "1"},
+		},
+		{
+			name:    "sqlite contains",
+			columns: []Column{{Name: "attrs.tags", Exp: Contains, Value: "sale"}},
+			dialect: DialectSQLite,
+			want:    "EXISTS (SELECT 1 FROM json_each(attrs, '$.tags') WHERE json_each.value = ?)",
+			args:    []interface{}{"sale"},
+		},
+		{
+			name:    "postgres contains",
+			columns: []Column{{Name: "attrs.tags", Exp: Contains, Value: "sale"}},
+			dialect: DialectPostgres,
+			want:    "attrs #> '{tags}' @> ?::jsonb",
+			args:    []interface{}{`"sale"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Params{Columns: tt.columns}
+			got, args, err := p.ConvertToGormConditions(tt.dialect)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestParams_ConvertToGormConditions_invalidJSON(t *testing.T) {
+	names := []string{
+		"attrs.color'",
+		"attrs->color",
+		"attrs.sizes[a]",
+		"attrs..color",
+		"a-b.color",
+		"attrs.color; DROP TABLE user",
+	}
+	for _, name := range names {
+		p := &Params{Columns: []Column{{Name: name, Value: 1}}}
+		_, _, err := p.ConvertToGormConditions()
+		assert.Error(t, err, name)
+	}
+
+	p := &Params{Columns: []Column{{Name: "(attrs)", Exp: Contains, Value: 1}}}
+	_, _, err := p.ConvertToGormConditions()
+	assert.Error(t, err)
+}
+
+func TestParams_ConvertToGormConditions_qualified(t *testing.T) {
+	p := &Params{
+		Columns:     []Column{{Name: "user.name", Value: "bob"}, {Name: "shop.user.age", Exp: Gt, Value: 18}, {Name: "attrs.color", Value: "red"}},
+		JSONColumns: []string{"attrs"},
+	}
+	got, args, err := p.ConvertToGormConditions()
+	assert.NoError(t, err)
+	assert.Equal(t, "user.name = ? AND shop.user.age > ? AND attrs->>'$.color' = ?", got)
+	assert.Equal(t, []interface{}{"bob", 18, "red"}, args)
+
+	// a json path of the filter
+	columns, err := ParseFilter(`attrs.color = "red"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "attrs->$.color", columns[0].Name)
+}
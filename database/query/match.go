@@ -0,0 +1,174 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Relevance the alias of the relevance score of the match columns, sort by it with Page, example: NewPage(0, 20, "-relevance"),
+// the score is selected when the page is sorted by it or the model has a read only field to receive it, example:
+//
+//	Relevance float64 `gorm:"column:relevance;->;-:migration" json:"relevance"`
+const Relevance = "relevance"
+
+// the columns of a match, example: title,body
+func matchColumns(name string) ([]string, error) {
+	columns := []string{}
+	for _, column := range strings.Split(name, ",") {
+		column = strings.TrimSpace(column)
+		for _, segment := range strings.Split(column, ".") {
+			if !isIdentifier(segment) {
+				return nil, fmt.Errorf("invalid match columns '%s'", name)
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// the words of a boolean mode search, for the like fallback of the other dialects,
+// the words prefixed by - are excluded and a quoted phrase is one word, example: +go -java "web server*"
+func matchWords(term string) (include []string, exclude []string) {
+	for term = strings.TrimSpace(term); term != ""; term = strings.TrimSpace(term) {
+		excluded := false
+		for term != "" && strings.ContainsRune("+-<>~(", rune(term[0])) {
+			excluded = excluded || term[0] == '-'
+			term = term[1:]
+		}
+
+		var word string
+		if strings.HasPrefix(term, `"`) {
+			end := strings.IndexByte(term[1:], '"')
+			if end < 0 {
+				word, term = term[1:], ""
+			} else {
+				word, term = term[1:end+1], term[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(term, " \t\n")
+			if end < 0 {
+				end = len(term)
+			}
+			word, term = term[:end], term[end:]
+		}
+
+		word = strings.TrimSpace(strings.TrimRight(word, "*)"))
+		switch {
+		case word == "":
+		case excluded:
+			exclude = append(exclude, word)
+		default:
+			include = append(include, word)
+		}
+	}
+	return include, exclude
+}
+
+// full text search of the value in the columns of the match, in boolean mode for mysql,
+// every word in any of the columns with like for the other dialects
+func (c *Column) match(dialect string) (string, []interface{}, error) {
+	columns, err := matchColumns(c.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	term := strings.TrimSpace(fmt.Sprint(c.Value))
+	if dialect == DialectMySQL && term != "" {
+		return fmt.Sprintf("MATCH(%s) AGAINST(? IN BOOLEAN MODE)", strings.Join(columns, ",")), []interface{}{term}, nil
+	}
+
+	include, exclude := matchWords(term)
+	if len(include)+len(exclude) == 0 {
+		return "", nil, fmt.Errorf("match value of columns '%s' cannot be empty", c.Name)
+	}
+	like := likeOperator(dialect)
+	parts := []string{}
+	args := []interface{}{}
+	anyColumn := func(word string) string {
+		ors := []string{}
+		for _, column := range columns {
+			ors = append(ors, column+like+"?"+likeEscape)
+			args = append(args, "%"+likeEscaper.Replace(word)+"%")
+		}
+		return "(" + strings.Join(ors, " OR ") + ")"
+	}
+	for _, word := range include {
+		parts = append(parts, anyColumn(word))
+	}
+	for _, word := range exclude {
+		parts = append(parts, "NOT "+anyColumn(word))
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", args, nil
+}
+
+// the relevance score of the match, the number of words found in each column for the other dialects than mysql
+func (c *Column) relevance(dialect string) (string, []interface{}, error) {
+	if dialect == DialectMySQL {
+		return c.match(dialect)
+	}
+
+	columns, err := matchColumns(c.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	include, _ := matchWords(fmt.Sprint(c.Value))
+	like := likeOperator(dialect)
+	cases := []string{}
+	args := []interface{}{}
+	for _, word := range include {
+		for _, column := range columns {
+			cases = append(cases, "CASE WHEN "+column+like+"?"+likeEscape+" THEN 1 ELSE 0 END")
+			args = append(args, "%"+likeEscaper.Replace(word)+"%")
+		}
+	}
+	if len(cases) == 0 {
+		return "0", nil, nil
+	}
+	return "(" + strings.Join(cases, " + ") + ")", args, nil
+}
+
+// the words are searched literally, their wildcards are escaped by a backslash
+const likeEscape = ` ESCAPE '\'`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func likeOperator(dialect string) string {
+	if dialect == DialectPostgres {
+		return " ILIKE "
+	}
+	return " LIKE "
+}
+
+// ConvertToRelevance the sql and args of the relevance score of the match columns of the Columns and Filter parameters,
+// the sum of their scores when there are several, an empty sql when there is no match column.
+// the optional dialect is the same as of ConvertToGormConditions
+func (p *Params) ConvertToRelevance(dialect ...string) (string, []interface{}, error) {
+	columns, err := p.conditions()
+	if err != nil {
+		return "", nil, err
+	}
+	d := DialectMySQL
+	if len(dialect) > 0 && dialect[0] != "" {
+		d = dialect[0]
+	}
+
+	scores := []string{}
+	args := []interface{}{}
+	for _, column := range columns {
+		if strings.ToLower(column.Exp) != Match {
+			continue
+		}
+		score, scoreArgs, err := column.relevance(d)
+		if err != nil {
+			return "", nil, err
+		}
+		scores = append(scores, score)
+		args = append(args, scoreArgs...)
+	}
+	if len(scores) == 0 {
+		return "", nil, nil
+	}
+	if len(scores) == 1 {
+		return scores[0], args, nil
+	}
+	return "(" + strings.Join(scores, " + ") + ")", args, nil
+}
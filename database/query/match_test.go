@@ -0,0 +1,94 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParams_match(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		value   interface{}
+		want    string
+		args    []interface{}
+		score   string
+	}{
+		{
+			name:    "mysql",
+			dialect: DialectMySQL,
+			value:   "+go -java",
+			want:    "MATCH(title,body) AGAINST(? IN BOOLEAN MODE)",
+			args:    []interface{}{"+go -java"},
+			score:   "MATCH(title,body) AGAINST(? IN BOOLEAN MODE)",
+		},
+		{
+			name:    "sqlite",
+			dialect: DialectSQLite,
+			value:   `+go -java "web server*"`,
+			want:    "((title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\') AND (title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\') AND NOT (title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\'))",
+			args:    []interface{}{"%go%", "%go%", "%web server%", "%web server%", "%java%", "%java%"},
+			score:   "(CASE WHEN title LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN title LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END)",
+		},
+		{
+			name:    "postgres",
+			dialect: DialectPostgres,
+			value:   "Go",
+			want:    "((title ILIKE ? ESCAPE '\\' OR body ILIKE ? ESCAPE '\\'))",
+			args:    []interface{}{"%Go%", "%Go%"},
+			score:   "(CASE WHEN title ILIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body ILIKE ? ESCAPE '\\' THEN 1 ELSE 0 END)",
+		},
+		{
+			name:    "wildcards",
+			dialect: DialectSQLite,
+			value:   `50% a_b c\d`,
+			want:    "((title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\') AND (title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\') AND (title LIKE ? ESCAPE '\\' OR body LIKE ? ESCAPE '\\'))",
+			args:    []interface{}{`%50\%%`, `%50\%%`, `%a\_b%`, `%a\_b%`, `%c\\d%`, `%c\\d%`},
+			score:   "(CASE WHEN title LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN title LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN title LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END + CASE WHEN body LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &Params{Columns: []Column{
+				{Name: "status", Value: 1},
+				{Name: "title, body", Exp: "MATCH", Value: tt.value},
+			}}
+			got, args, err := params.ConvertToGormConditions(tt.dialect)
+			require.NoError(t, err)
+			assert.Equal(t, "status = ? AND "+tt.want, got)
+			assert.Equal(t, append([]interface{}{1}, tt.args...), args)
+
+			score, _, err := params.ConvertToRelevance(tt.dialect)
+			require.NoError(t, err)
+			assert.Equal(t, tt.score, score)
+		})
+	}
+}
+
+func TestParams_match_error(t *testing.T) {
+	_, _, err := (&Params{Columns: []Column{{Name: "title", Exp: Match, Value: " + "}}}).ConvertToGormConditions(DialectSQLite)
+	assert.EqualError(t, err, "match value of columns 'title' cannot be empty")
+
+	_, _, err = (&Params{Columns: []Column{{Name: "title;body", Exp: Match, Value: "go"}}}).ConvertToGormConditions()
+	assert.EqualError(t, err, "invalid match columns 'title;body'")
+
+	err = (&Params{Columns: []Column{{Name: "title,secret", Exp: Match, Value: "go"}}}).CheckColumns([]string{"title"})
+	assert.EqualError(t, err, "column 'secret' cannot be queried")
+
+	score, args, err := (&Params{Columns: []Column{{Name: "title", Value: "go"}}}).ConvertToRelevance()
+	require.NoError(t, err)
+	assert.Empty(t, score)
+	assert.Empty(t, args)
+}
+
+func TestParseFilter_match(t *testing.T) {
+	columns, err := ParseFilter(`title match "+go -java"`, "title")
+	require.NoError(t, err)
+	got, args, err := (&Params{Columns: columns}).ConvertToGormConditions()
+	require.NoError(t, err)
+	assert.Equal(t, "MATCH(title) AGAINST(? IN BOOLEAN MODE)", got)
+	assert.Equal(t, []interface{}{"+go -java"}, args)
+}
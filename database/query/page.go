@@ -54,6 +54,7 @@ func DefaultPage(page int) *Page {
 // NewPage custom page, starting from page 0.
 // the parameter columnNames indicates a sort field, if empty means id descending, if there are multiple column names, separated by a comma,
 // a '-' sign in front of each column name indicates descending order, otherwise ascending order.
+// the column name relevance sorts by the relevance score of the match columns of the params of ListByParams, example: -relevance.
 func NewPage(page int, size int, columnNames string) *Page {
 	if page < 0 {
 		page = 0
@@ -20,6 +20,12 @@ const (
 	Lte = "lte"
 	// Like like
 	Like = "like"
+	// Contains the json value contains the value, an element of an array or the value itself
+	Contains = "contains"
+	// Match full text search of the value in the columns separated by a comma, example: title,body
+	Match = "match"
+	// Between the date is in a relative date range, example: last_7_days, -30d..now, see Params.Timezone
+	Between = "between"
 
 	// AND logic and
 	AND string = "and"
@@ -60,15 +66,25 @@ type Params struct {
 	Size int    `form:"size" binding:"gt=0" json:"size"`
 	Sort string `form:"sort" binding:"" json:"sort,omitempty"`
 
-	Columns []Column `json:"columns,omitempty"` // not required
+	Columns []Column `json:"columns,omitempty"`              // not required
+	Filter  string   `form:"filter" json:"filter,omitempty"` // one line filter combined with Columns by and, see ParseFilter, example: status in (1,2) and name ~ "bob"
+
+	Include  []string `form:"include" json:"include,omitempty"` // relations to preload, validated by ConvertToIncludes, example: author,comments.user
+	Fields   []string `form:"fields" json:"fields,omitempty"`   // columns to select, validated by ConvertToFields, example: id,title
+	Timezone string   `form:"tz" json:"tz,omitempty"`           // timezone of the relative dates of the columns and filter, the local timezone by default, example: Asia/Shanghai
+
+	JSONColumns []string `form:"-" json:"-"` // json columns whose dotted names in Columns are paths, example: attrs for attrs.color, any dotted name is a path when nil, set from the model by ListByParams and GetByParams
+	DateColumns []string `form:"-" json:"-"` // date columns whose values are relative dates without the date: prefix too, example: created_at with today, set from the model by ListByParams and GetByParams
 }
 
 // Column search information
 type Column struct {
-	Name  string      `json:"name"`  // column name
-	Exp   string      `json:"exp"`   // expressions, which default to = when the value is null, have =, ! =, >, >=, <, <=, like
-	Value interface{} `json:"value"` // column value
+	Name  string      `json:"name"`  // column name, or a path inside a json column, example: attrs.color, attrs.sizes[0], attrs->$.size, a table qualified column when its table is not one of the JSONColumns, example: user.name, or the columns of a match, example: title,body
+	Exp   string      `json:"exp"`   // expressions, which default to = when the value is null, have =, ! =, >, >=, <, <=, like, contains, match, between
+	Value interface{} `json:"value"` // column value, or a relative date compared with the bounds of its range with between, the date: prefix or a column of the DateColumns, example: date:today, last_7_days, -30d..now
 	Logic string      `json:"logic"` // logical type, defaults to and when the value is null, with &(and), ||(or)
+
+	date bool // the column is one of the DateColumns
 }
 
 func (c *Column) checkValid() error {
@@ -86,7 +102,9 @@ func (c *Column) convert() error {
 	if c.Exp == "" {
 		c.Exp = Eq
 	}
-	if v, ok := expMap[strings.ToLower(c.Exp)]; ok {
+	if exp := strings.ToLower(c.Exp); exp == Contains || exp == Match || exp == Between {
+		c.Exp = exp
+	} else if v, ok := expMap[strings.ToLower(c.Exp)]; ok {
 		c.Exp = v
 		if c.Exp == " LIKE " {
 			c.Value = fmt.Sprintf("%%%v%%", c.Value)
@@ -107,6 +125,49 @@ func (c *Column) convert() error {
 	return nil
 }
 
+// condition of the column in the sql of dialect, with its arguments, the relative dates are resolved in the timezone
+func (c *Column) condition(dialect string, timezone string) (string, []interface{}, error) {
+	switch c.Exp {
+	case Match:
+		return c.match(dialect)
+	case Contains:
+	default:
+		if c.Exp != " LIKE " {
+			condition, args, ok, err := c.dateCondition(dialect, timezone)
+			if ok || err != nil {
+				return condition, args, err
+			}
+		}
+		name, err := c.expression(dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		return name + c.Exp + "?", []interface{}{c.Value}, nil
+	}
+
+	path, isJSON, err := parseJSONPath(c.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if !isJSON {
+		if !isIdentifier(c.Name) {
+			return "", nil, fmt.Errorf("invalid json column '%s'", c.Name)
+		}
+		path = jsonPath{column: c.Name}
+	}
+	condition, arg, err := path.contains(dialect, c.Value)
+	return condition, []interface{}{arg}, err
+}
+
+// the column name, or the expression of the value at its json path
+func (c *Column) expression(dialect string) (string, error) {
+	path, isJSON, err := parseJSONPath(c.Name)
+	if err != nil || !isJSON {
+		return c.Name, err
+	}
+	return path.extract(dialect), nil
+}
+
 // ConvertToPage converted to conform to gorm rules based on the page size sort parameter
 func (p *Params) ConvertToPage() (order string, limit int, offset int) {
 	page := NewPage(p.Page, p.Size, p.Sort)
@@ -117,11 +178,16 @@ func (p *Params) ConvertToPage() (order string, limit int, offset int) {
 }
 
 // ConvertToGormConditions conversion to gorm-compliant parameters based on the Columns parameter
-// ignore the logical type of the last column, whether it is a one-column or multi-column query
-func (p *Params) ConvertToGormConditions() (string, []interface{}, error) {
+// ignore the logical type of the last column, whether it is a one-column or multi-column query.
+// the optional dialect selects the sql of json paths, mysql by default, example: db.Dialector.Name()
+func (p *Params) ConvertToGormConditions(dialect ...string) (string, []interface{}, error) {
 	str := ""
 	args := []interface{}{}
-	l := len(p.Columns)
+	columns, err := p.conditions()
+	if err != nil {
+		return "", nil, err
+	}
+	l := len(columns)
 	if l == 0 {
 		return "", nil, nil
 	}
@@ -130,9 +196,13 @@ func (p *Params) ConvertToGormConditions() (string, []interface{}, error) {
 	if l == 1 {
 		isUseIN = false
 	}
-	field := p.Columns[0].Name
+	field := columns[0].Name
+	d := DialectMySQL
+	if len(dialect) > 0 && dialect[0] != "" {
+		d = dialect[0]
+	}
 
-	for i, column := range p.Columns {
+	for i, column := range columns {
 		if err := column.checkValid(); err != nil {
 			return "", nil, err
 		}
@@ -142,26 +212,33 @@ func (p *Params) ConvertToGormConditions() (string, []interface{}, error) {
 			return "", nil, err
 		}
 
+		column.date = p.isDateColumn(column.Name)
+		condition, conditionArgs, err := column.condition(d, p.Timezone)
+		if err != nil {
+			return "", nil, err
+		}
+
 		if i == l-1 { // ignore the logical type of the last column
-			str += column.Name + column.Exp + "?"
+			str += condition
 		} else {
-			str += column.Name + column.Exp + "?" + column.Logic
+			str += condition + column.Logic
 		}
-		args = append(args, column.Value)
+		args = append(args, conditionArgs...)
 
 		if isUseIN {
 			if field != column.Name {
 				isUseIN = false
 				continue
 			}
-			if column.Exp != expMap[Eq] {
+			if column.Exp != expMap[Eq] || len(conditionArgs) != 1 {
 				isUseIN = false
 			}
 		}
 	}
 
 	if isUseIN {
-		str = field + " IN (?)"
+		name, _ := columns[0].expression(d) // validated by the condition of the first column
+		str = name + " IN (?)"
 		args = []interface{}{args}
 	}
 
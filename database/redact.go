@@ -0,0 +1,272 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// RedactTag struct tag of the model fields whose values are masked in the logged SQL, example:
+	//
+	//	Password string `gorm:"column:password" redact:"true"`
+	//
+	// the column is masked in every table from the first statement on the model, as the columns of WithRedactColumns
+	RedactTag = "redact"
+
+	redactedValue = "***"
+)
+
+// redactor mask the values bound to sensitive columns before the SQL is logged, the columns are global to the
+// tables of a connection because gorm filters the values of the SQL without its statement, and a table guessed
+// from the SQL would miss the columns of joined tables
+type redactor struct {
+	mu       sync.RWMutex
+	columns  map[string]bool // lower case column names of any table
+	patterns []*regexp.Regexp
+
+	schemas sync.Map // *schema.Schema -> scanned for the redact tag
+}
+
+func newRedactor(columns []string, patterns []*regexp.Regexp) *redactor {
+	r := &redactor{columns: map[string]bool{}, patterns: patterns}
+	r.addColumns(columns...)
+	return r
+}
+
+func (r *redactor) addColumns(columns ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, column := range columns {
+		r.columns[strings.ToLower(column)] = true
+	}
+}
+
+func (r *redactor) isRedacted(column string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.columns[column]
+}
+
+// add the columns of the fields with the redact tag, each schema is scanned once
+func (r *redactor) addSchema(s *schema.Schema) {
+	if _, loaded := r.schemas.LoadOrStore(s, true); loaded {
+		return
+	}
+	for _, field := range s.Fields {
+		if v, ok := field.Tag.Lookup(RedactTag); ok && v != "false" && field.DBName != "" {
+			r.addColumns(field.DBName)
+		}
+	}
+}
+
+// redact return a copy of vars with the values of redacted columns masked,
+// and the parts of string values matching a pattern masked
+func (r *redactor) redact(sql string, vars []interface{}) []interface{} {
+	r.mu.RLock()
+	hasColumns := len(r.columns) > 0
+	r.mu.RUnlock()
+	if len(vars) == 0 || (!hasColumns && len(r.patterns) == 0) {
+		return vars
+	}
+
+	var columns []string
+	if hasColumns {
+		columns = placeholderColumns(sql)
+	}
+
+	redacted := make([]interface{}, len(vars))
+	for i, v := range vars {
+		if i < len(columns) && columns[i] != "" && v != nil && r.isRedacted(columns[i]) {
+			redacted[i] = redactedValue
+			continue
+		}
+		redacted[i] = r.redactPatterns(v)
+	}
+	return redacted
+}
+
+func (r *redactor) redactPatterns(v interface{}) interface{} {
+	var s string
+	switch value := v.(type) {
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	case *string:
+		if value == nil {
+			return v
+		}
+		s = *value
+	default:
+		return v
+	}
+
+	masked := s
+	for _, pattern := range r.patterns {
+		masked = pattern.ReplaceAllString(masked, redactedValue)
+	}
+	if masked == s {
+		return v
+	}
+	return masked
+}
+
+// register callbacks adding the redact tags of the statement models before the statements are logged
+func registerRedactCallbacks(db *gorm.DB) error {
+	l, ok := db.Config.Logger.(*logger)
+	if !ok || l.redactor == nil {
+		return nil
+	}
+
+	fn := func(db *gorm.DB) {
+		if db.Statement.Schema != nil {
+			l.redactor.addSchema(db.Statement.Schema)
+		}
+	}
+	cb := db.Callback()
+	befores := []registerFunc{
+		cb.Query().Before("gorm:query").Register,
+		cb.Row().Before("gorm:row").Register,
+		cb.Create().Before("gorm:create").Register,
+		cb.Update().Before("gorm:update").Register,
+		cb.Delete().Before("gorm:delete").Register,
+	}
+	for _, before := range befores {
+		if err := before("library:redact_schema", fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sql keywords that are not column names
+var sqlKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "like": true, "in": true, "is": true, "null": true, "between": true,
+	"select": true, "from": true, "where": true, "set": true, "update": true, "insert": true, "replace": true,
+	"into": true, "delete": true, "values": true, "order": true, "group": true, "by": true, "having": true,
+	"on": true, "as": true, "asc": true, "desc": true, "case": true, "when": true, "then": true, "else": true,
+	"end": true, "exists": true, "escape": true, "distinct": true, "join": true, "left": true, "right": true,
+	"inner": true, "outer": true, "true": true, "false": true, "interval": true, "regexp": true,
+	"duplicate": true, "key": true, "conflict": true, "do": true, "returning": true,
+}
+
+// placeholderColumns the column each ? placeholder of sql is bound to, in order, lower case,
+// empty when unknown. the values of an INSERT are matched with its column list,
+// other placeholders are bound to the last column named before them, example: name = ?, age IN (?,?)
+func placeholderColumns(sql string) []string {
+	const (
+		stageTable   = iota // before the column list of an insert
+		stageColumns        // in the column list
+		stageInsert         // after the column list
+		stageValues         // in the values
+		stageOther          // any other statement, or after the values
+	)
+
+	stage := stageOther
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		if first := strings.ToLower(fields[0]); first == "insert" || first == "replace" {
+			stage = stageTable
+		}
+	}
+
+	columns := []string{}
+	insertColumns := []string{}
+	last := ""
+	depth := 0
+	position := 0 // of the value in the current row of an insert
+
+	ident := func(name string) {
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+		name = strings.ToLower(name)
+		if stage == stageColumns && depth == 1 {
+			insertColumns = append(insertColumns, name)
+		}
+		last = name
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			for i++; i < len(sql); i++ {
+				if sql[i] == '\\' {
+					i++
+				} else if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c { // doubled quote
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '`':
+			j := strings.IndexByte(sql[i+1:], '`')
+			if j < 0 {
+				return columns
+			}
+			name := sql[i+1 : i+1+j]
+			i += j + 1
+			// a qualified name, example: `user`.`name`
+			for i+2 < len(sql) && sql[i+1] == '.' && sql[i+2] == '`' {
+				k := strings.IndexByte(sql[i+3:], '`')
+				if k < 0 {
+					break
+				}
+				name = sql[i+3 : i+3+k]
+				i += k + 3
+			}
+			ident(name)
+		case isIdentChar(c) && !isDigit(c):
+			j := i
+			for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '.') {
+				j++
+			}
+			word := sql[i:j]
+			i = j - 1
+			lower := strings.ToLower(word)
+			if !sqlKeywords[lower] {
+				ident(word)
+				continue
+			}
+			switch {
+			case lower == "values" && stage == stageInsert:
+				stage = stageValues
+			case lower == "on" && stage == stageValues:
+				stage = stageOther
+			}
+		case c == '(':
+			depth++
+			if stage == stageTable && depth == 1 {
+				stage = stageColumns
+			} else if stage == stageValues && depth == 1 {
+				position = 0
+			}
+		case c == ')':
+			depth--
+			if stage == stageColumns && depth == 0 {
+				stage = stageInsert
+			}
+		case c == ',':
+			if stage == stageValues && depth == 1 {
+				position++
+			}
+		case c == '?':
+			if stage == stageValues && depth > 0 {
+				column := ""
+				if position < len(insertColumns) {
+					column = insertColumns[position]
+				}
+				columns = append(columns, column)
+			} else {
+				columns = append(columns, last)
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+)
+
+func TestPlaceholderColumns(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT * FROM `user` WHERE phone = ? AND `user`.`age` IN (?,?) LIMIT ?", []string{"phone", "age", "age", "limit"}},
+		{"SELECT * FROM user WHERE name = 'a?b' AND token = ?", []string{"token"}},
+		{"UPDATE `user` SET `password`=?,`updated_at`=? WHERE id = ?", []string{"password", "updated_at", "id"}},
+		{"INSERT INTO `user` (`name`,`password`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `name`=?",
+			[]string{"name", "password", "name", "password", "name"}},
+		{"INSERT INTO user (created_at, phone) VALUES (NOW(), ?)", []string{"phone"}},
+		{"SELECT * FROM user WHERE age BETWEEN ? AND ?", []string{"age", "age"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, placeholderColumns(tt.sql), tt.sql)
+	}
+}
+
+type redactUserExample struct {
+	ID       uint64 `gorm:"column:id;primary_key"`
+	Name     string `gorm:"column:name"`
+	Password string `gorm:"column:password" redact:"true"`
+	Phone    string `gorm:"column:phone"`
+	Remark   string `gorm:"column:remark"`
+}
+
+type redactAccountExample struct {
+	ID       uint64 `gorm:"column:id;primary_key"`
+	Password string `gorm:"column:password"`
+}
+
+func TestRedact(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithMaxOpenConns(1), WithConnMaxLifetime(0),
+		WithLog(true, zap.New(core), 0),
+		WithRedactColumns("phone"),
+		WithRedactPatterns(regexp.MustCompile(`\d{11}`)),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&redactUserExample{}))
+
+	logs.TakeAll()
+	user := &redactUserExample{ID: 1, Name: "Alice", Password: "secret", Phone: "13800138000", Remark: "call 13900139000"}
+	assert.NoError(t, db.Create(user).Error)
+	assert.NoError(t, db.Where("phone = ?", "13800138000").First(&redactUserExample{}).Error)
+
+	entries := logs.FilterMessage("trace").AllUntimed()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "INSERT INTO `redact_user_example` (`name`,`password`,`phone`,`remark`,`id`) "+
+		`VALUES ("Alice","***","***","call ***",1) RETURNING `+"`id`", entries[0].ContextMap()["sql"])
+	assert.Equal(t, "SELECT * FROM `redact_user_example` WHERE phone = \"***\" "+
+		"ORDER BY `redact_user_example`.`id` LIMIT 1", entries[1].ContextMap()["sql"])
+
+	// the tagged column is masked in every table
+	assert.NoError(t, db.AutoMigrate(&redactAccountExample{}))
+	logs.TakeAll()
+	assert.NoError(t, db.Create(&redactAccountExample{ID: 1, Password: "secret"}).Error)
+	entries = logs.FilterMessage("trace").AllUntimed()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "INSERT INTO `redact_account_example` (`password`,`id`) VALUES (\"***\",1) RETURNING `id`",
+		entries[0].ContextMap()["sql"])
+}
+
+func TestRedact_parameterized(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithMaxOpenConns(1), WithConnMaxLifetime(0),
+		WithLog(true, zap.New(core), 0), WithParameterizedLog())
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&redactUserExample{}))
+
+	logs.TakeAll()
+	assert.NoError(t, db.Where("phone = ? AND name IN ?", "13800138000", []string{"a", "b"}).Find(&[]redactUserExample{}).Error)
+
+	entries := logs.FilterMessage("trace").AllUntimed()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "SELECT * FROM `redact_user_example` WHERE phone = ? AND name IN (?,?)", fields["sql"])
+	assert.Equal(t, "select * from `redact_user_example` where phone = ? and name in (?+)", fields["fingerprint"])
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var defaultRegistry = NewRegistry()
+
+// Source settings of a named connection, when Config is set the DSN is built from it
+// and Options are applied after the options of the config
+type Source struct {
+	DSN     string
+	Config  *Config
+	Options []Option
+}
+
+// Health state of a named connection
+type Health struct {
+	Name    string
+	Err     error         // ping error, nil when the connection is healthy
+	Latency time.Duration // ping round trip
+	Stats   sql.DBStats   // connection pool statistics
+}
+
+// Registry named database connections
+type Registry struct {
+	mu   sync.RWMutex
+	dbs  map[string]*gorm.DB
+	open func(dsn string, opts ...Option) (*gorm.DB, error)
+}
+
+// NewRegistry create an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		dbs:  map[string]*gorm.DB{},
+		open: Open,
+	}
+}
+
+// Open a connection for each source, if one of them fails the connections opened by this call are closed again
+func (r *Registry) Open(sources map[string]Source) error {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		if _, ok := r.Lookup(name); ok {
+			return fmt.Errorf("database '%s' is already registered", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	opened := make(map[string]*gorm.DB, len(sources))
+	closeOpened := func() {
+		for _, db := range opened {
+			_ = closeDB(db)
+		}
+	}
+	for _, name := range names {
+		source := sources[name]
+		dsn, opts := source.DSN, source.Options
+		if source.Config != nil {
+			if err := source.Config.Validate(); err != nil {
+				closeOpened()
+				return fmt.Errorf("open database '%s' error, err: %w", name, err)
+			}
+			dsn, opts = source.Config.DSN(), append(source.Config.Options(), opts...)
+		}
+		db, err := r.open(dsn, opts...)
+		if err != nil {
+			closeOpened()
+			return fmt.Errorf("open database '%s' error, err: %w", name, err)
+		}
+		opened[name] = db
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range opened {
+		if _, ok := r.dbs[name]; ok {
+			closeOpened()
+			return fmt.Errorf("database '%s' is already registered", name)
+		}
+	}
+	for name, db := range opened {
+		r.dbs[name] = db
+	}
+	return nil
+}
+
+// Register add an opened connection under name
+func (r *Registry) Register(name string, db *gorm.DB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dbs[name]; ok {
+		return fmt.Errorf("database '%s' is already registered", name)
+	}
+	r.dbs[name] = db
+	return nil
+}
+
+// Lookup get the connection registered under name
+func (r *Registry) Lookup(name string) (*gorm.DB, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	db, ok := r.dbs[name]
+	return db, ok
+}
+
+// Use get the connection registered under name, panic if there is none
+func (r *Registry) Use(name string) *gorm.DB {
+	db, ok := r.Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("database '%s' is not registered", name))
+	}
+	return db
+}
+
+// Names of the registered connections, in name order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.dbs))
+	for name := range r.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Health ping every connection, in name order
+func (r *Registry) Health(ctx context.Context) []Health {
+	names := r.Names()
+	result := make([]Health, 0, len(names))
+	for _, name := range names {
+		db, ok := r.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		h := Health{Name: name}
+		sqlDB, err := db.DB()
+		if err != nil {
+			h.Err = err
+			result = append(result, h)
+			continue
+		}
+		begin := time.Now()
+		h.Err = sqlDB.PingContext(ctx)
+		h.Latency = time.Since(begin)
+		h.Stats = sqlDB.Stats()
+		result = append(result, h)
+	}
+	return result
+}
+
+// Close every connection and empty the registry, queries already running are allowed to finish
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	dbs := r.dbs
+	r.dbs = map[string]*gorm.DB{}
+	r.mu.Unlock()
+
+	var errs []error
+	for name, db := range dbs {
+		if err := closeDB(db); err != nil {
+			errs = append(errs, fmt.Errorf("close database '%s' error, err: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// OpenAll open the named connections of the default registry
+func OpenAll(sources map[string]Source) error {
+	return defaultRegistry.Open(sources)
+}
+
+// Register add an opened connection to the default registry
+func Register(name string, db *gorm.DB) error {
+	return defaultRegistry.Register(name, db)
+}
+
+// Use get a connection of the default registry, panic if there is none, example: database.Use("billing")
+func Use(name string) *gorm.DB {
+	return defaultRegistry.Use(name)
+}
+
+// Lookup get a connection of the default registry
+func Lookup(name string) (*gorm.DB, bool) {
+	return defaultRegistry.Lookup(name)
+}
+
+// HealthCheck ping every connection of the default registry
+func HealthCheck(ctx context.Context) []Health {
+	return defaultRegistry.Health(ctx)
+}
+
+// CloseAll close every connection of the default registry
+func CloseAll() error {
+	return defaultRegistry.Close()
+}
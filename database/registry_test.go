@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.open = func(dsn string, opts ...Option) (*gorm.DB, error) {
+		if dsn == "" {
+			return nil, errors.New("empty dsn")
+		}
+		return OpenDialector(sqlite.Open(dsn), opts...)
+	}
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := newTestRegistry()
+	err := r.Open(map[string]Source{
+		"billing": {DSN: ":memory:", Options: []Option{WithMaxOpenConns(1)}},
+		"users":   {DSN: ":memory:"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"billing", "users"}, r.Names())
+
+	db := r.Use("billing")
+	assert.NotNil(t, db)
+	_, ok := r.Lookup("orders")
+	assert.False(t, ok)
+	assert.Panics(t, func() { r.Use("orders") })
+
+	// names must be unique
+	err = r.Open(map[string]Source{"users": {DSN: ":memory:"}})
+	assert.Error(t, err)
+	err = r.Register("billing", db)
+	assert.Error(t, err)
+
+	health := r.Health(context.Background())
+	assert.Len(t, health, 2)
+	assert.Equal(t, "billing", health[0].Name)
+	assert.NoError(t, health[0].Err)
+
+	assert.NoError(t, r.Close())
+	assert.Empty(t, r.Names())
+	assert.Error(t, db.Exec("SELECT 1").Error)
+}
+
+func TestRegistry_OpenError(t *testing.T) {
+	r := newTestRegistry()
+	err := r.Open(map[string]Source{
+		"billing": {DSN: ":memory:"},
+		"users":   {DSN: ""},
+	})
+	assert.Error(t, err)
+	assert.Empty(t, r.Names())
+}
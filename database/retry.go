@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xingmoo/library/database/query"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RetryOption set the retry policy of Transaction.
+type RetryOption func(*retryOptions)
+
+type retryOptions struct {
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	retryable   func(err error) bool
+	txOptions   *sql.TxOptions
+	logger      *zap.Logger
+}
+
+func (o *retryOptions) apply(opts ...RetryOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// default retry policy
+func defaultRetryOptions(db *gorm.DB) *retryOptions {
+	o := &retryOptions{
+		maxAttempts: 3, // number of runs of the transaction, including the first one
+		minBackoff:  20 * time.Millisecond,
+		maxBackoff:  time.Second,
+		retryable:   query.IsRetryable, // deadlock, lock wait timeout, lost connection
+		logger:      zap.NewNop(),
+	}
+	// the logger set by WithLog
+	if l, ok := db.Config.Logger.(*logger); ok && l.logger != nil {
+		o.logger = l.logger
+	}
+	return o
+}
+
+// WithRetryAttempts set the maximum number of runs of the transaction, including the first one
+func WithRetryAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithRetryBackoff set the bounds of the exponential delay between runs
+func WithRetryBackoff(min time.Duration, max time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithRetryable set the classifier of the errors worth a retry, default is query.IsRetryable
+func WithRetryable(fn func(err error) bool) RetryOption {
+	return func(o *retryOptions) {
+		o.retryable = fn
+	}
+}
+
+// WithTxOptions set the isolation level and read only mode of the transaction
+func WithTxOptions(txOptions *sql.TxOptions) RetryOption {
+	return func(o *retryOptions) {
+		o.txOptions = txOptions
+	}
+}
+
+// WithRetryLogger set the logger of the retries, default is the logger set by WithLog
+func WithRetryLogger(l *zap.Logger) RetryOption {
+	return func(o *retryOptions) {
+		o.logger = l
+	}
+}
+
+// Transaction run fn in a transaction, the whole transaction is rolled back and fn is run again
+// when it fails with a retryable error, example: a deadlock of concurrent inventory updates.
+// fn must only change the database through tx and must not keep state from a failed run.
+// no retry is made when db is already in a transaction, the outer transaction has to be retried instead.
+// the backoff between runs stops at the deadline of ctx, the driver errors are classified by query.ConvertError
+func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...RetryOption) error {
+	o := defaultRetryOptions(db)
+	o.apply(opts...)
+
+	db = db.WithContext(ctx)
+	if committer, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok && committer != nil {
+		return query.ConvertError(db.Transaction(fn))
+	}
+
+	for attempt := 1; ; attempt++ {
+		var txOptions []*sql.TxOptions
+		if o.txOptions != nil {
+			txOptions = append(txOptions, o.txOptions)
+		}
+		err := query.ConvertError(db.Transaction(fn, txOptions...))
+		if err == nil || !o.retryable(err) {
+			return err
+		}
+		if attempt >= o.maxAttempts {
+			return fmt.Errorf("transaction failed after %d attempts, err: %w", attempt, err)
+		}
+
+		wait := Backoff(attempt, o.minBackoff, o.maxBackoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return errors.Join(err, context.DeadlineExceeded)
+		}
+		o.logger.Warn("transaction retry", zap.Int("attempt", attempt), zap.Int("max_attempts", o.maxAttempts),
+			zap.Duration("backoff", wait), zap.Error(err))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database"
+	"github.com/xingmoo/library/database/dbtest"
+	"github.com/xingmoo/library/database/query"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+)
+
+var errDeadlock = &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &authorExample{})
+	core, logs := observer.New(zap.WarnLevel)
+
+	attempts := 0
+	err := database.Transaction(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		if err := tx.Create(&authorExample{Name: "Alice"}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errDeadlock
+		}
+		return nil
+	}, database.WithRetryBackoff(time.Millisecond, 2*time.Millisecond), database.WithRetryLogger(zap.New(core)))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, logs.FilterMessage("transaction retry").Len())
+
+	// the failed runs were rolled back
+	count, err := database.Count(ctx, db, &authorExample{}, "name = ?", "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTransaction_giveUp(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, &authorExample{})
+
+	attempts := 0
+	err := database.Transaction(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		return errDeadlock
+	}, database.WithRetryAttempts(2), database.WithRetryBackoff(time.Millisecond, time.Millisecond))
+	assert.ErrorIs(t, err, query.ErrDeadlock)
+	assert.Equal(t, 2, attempts)
+
+	// not retryable
+	attempts = 0
+	errInvalid := errors.New("invalid order")
+	err = database.Transaction(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		return errInvalid
+	})
+	assert.Equal(t, errInvalid, err)
+	assert.Equal(t, 1, attempts)
+
+	// the deadline of ctx is shorter than the backoff
+	attempts = 0
+	deadlineCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = database.Transaction(deadlineCtx, db, func(tx *gorm.DB) error {
+		attempts++
+		return errDeadlock
+	}, database.WithRetryBackoff(time.Minute, time.Minute))
+	assert.ErrorIs(t, err, query.ErrDeadlock)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// the outer transaction is retried instead
+	attempts = 0
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		err = database.Transaction(ctx, tx, func(tx *gorm.DB) error {
+			attempts++
+			return errDeadlock
+		})
+		return err
+	})
+	assert.ErrorIs(t, err, query.ErrDeadlock)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/gorm"
+)
+
+// Store the CRUD operations of the package on one database, services depending on a Store
+// can be tested with the in-memory store of the fake package instead of a database
+type Store interface {
+	Create(ctx context.Context, table interface{}) error
+	Delete(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error
+	DeleteByID(ctx context.Context, table interface{}, id interface{}) error
+	Update(ctx context.Context, table interface{}, column string, value interface{}, query interface{}, args ...interface{}) error
+	Updates(ctx context.Context, table interface{}, update KV, query interface{}, args ...interface{}) error
+	Get(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error
+	GetByID(ctx context.Context, table interface{}, id interface{}) error
+	List(ctx context.Context, tables interface{}, page *query.Page, query interface{}, args ...interface{}) error
+	Count(ctx context.Context, table interface{}, query interface{}, args ...interface{}) (int64, error)
+	ListByParams(ctx context.Context, tables interface{}, params *query.Params) error
+	GetByParams(ctx context.Context, table interface{}, params *query.Params) error
+}
+
+// NewStore the Store running the CRUD functions of the package on db
+func NewStore(db *gorm.DB) Store {
+	return &store{db: db}
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func (s *store) Create(ctx context.Context, table interface{}) error {
+	return Create(ctx, s.db, table)
+}
+
+func (s *store) Delete(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error {
+	return Delete(ctx, s.db, table, query, args...)
+}
+
+func (s *store) DeleteByID(ctx context.Context, table interface{}, id interface{}) error {
+	return DeleteByID(ctx, s.db, table, id)
+}
+
+func (s *store) Update(ctx context.Context, table interface{}, column string, value interface{}, query interface{}, args ...interface{}) error {
+	return Update(ctx, s.db, table, column, value, query, args...)
+}
+
+func (s *store) Updates(ctx context.Context, table interface{}, update KV, query interface{}, args ...interface{}) error {
+	return Updates(ctx, s.db, table, update, query, args...)
+}
+
+func (s *store) Get(ctx context.Context, table interface{}, query interface{}, args ...interface{}) error {
+	return Get(ctx, s.db, table, query, args...)
+}
+
+func (s *store) GetByID(ctx context.Context, table interface{}, id interface{}) error {
+	return GetByID(ctx, s.db, table, id)
+}
+
+func (s *store) List(ctx context.Context, tables interface{}, page *query.Page, query interface{}, args ...interface{}) error {
+	return List(ctx, s.db, tables, page, query, args...)
+}
+
+func (s *store) Count(ctx context.Context, table interface{}, query interface{}, args ...interface{}) (int64, error) {
+	return Count(ctx, s.db, table, query, args...)
+}
+
+func (s *store) ListByParams(ctx context.Context, tables interface{}, params *query.Params) error {
+	return ListByParams(ctx, s.db, tables, params)
+}
+
+func (s *store) GetByParams(ctx context.Context, table interface{}, params *query.Params) error {
+	return GetByParams(ctx, s.db, table, params)
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const timeoutCancelKey = "library:timeout_cancel"
+
+// ErrTimeout a statement exceeded the default timeout set by WithQueryTimeout or WithWriteTimeout,
+// use errors.Is(err, ErrTimeout) to check for it
+var ErrTimeout = errors.New("statement timeout")
+
+// TimeoutError statement cancelled because it exceeded the default timeout
+type TimeoutError struct {
+	Timeout time.Duration
+	SQL     string
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("statement exceeded timeout %s, sql: %s, err: %v", e.Timeout, e.SQL, e.Err)
+}
+
+// Unwrap return the driver error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether target is ErrTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// register callbacks deriving a deadline for each statement whose context has none.
+// the after callbacks run after library:convert_error, so that a timeout wraps the converted driver error
+func registerTimeoutCallbacks(db *gorm.DB, o *options) error {
+	cb := db.Callback()
+	query := func(*gorm.DB) time.Duration { return o.queryTimeout }
+	write := func(*gorm.DB) time.Duration { return o.writeTimeout }
+	// Exec runs any statement, a SELECT gets the query timeout
+	raw := func(db *gorm.DB) time.Duration {
+		if isSelect(Fingerprint(db.Statement.SQL.String())) {
+			return o.queryTimeout
+		}
+		return o.writeTimeout
+	}
+
+	type stage struct {
+		before, after registerFunc
+		timeout       func(*gorm.DB) time.Duration
+	}
+	stages := []stage{
+		{cb.Query().Before("gorm:query").Register, cb.Query().After("library:convert_error").Register, query},
+		{cb.Row().Before("gorm:row").Register, cb.Row().After("library:convert_error").Register, query},
+		{cb.Create().Before("gorm:create").Register, cb.Create().After("library:convert_error").Register, write},
+		{cb.Update().Before("gorm:update").Register, cb.Update().After("library:convert_error").Register, write},
+		{cb.Delete().Before("gorm:delete").Register, cb.Delete().After("library:convert_error").Register, write},
+		{cb.Raw().Before("gorm:raw").Register, cb.Raw().After("library:convert_error").Register, raw},
+	}
+	if o.queryTimeout <= 0 && o.writeTimeout <= 0 {
+		return nil
+	}
+
+	for _, s := range stages {
+		if err := registerTimeout(s.before, s.after, s.timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type registerFunc func(name string, fn func(*gorm.DB)) error
+
+// the deadline of a statement
+type statementTimeout struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func registerTimeout(before, after registerFunc, timeout func(*gorm.DB) time.Duration) error {
+	err := before("library:timeout_before", func(db *gorm.DB) {
+		d := timeout(db)
+		if d <= 0 {
+			return
+		}
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		db.Statement.Context = ctx
+		db.InstanceSet(timeoutCancelKey, &statementTimeout{timeout: d, cancel: cancel})
+	})
+	if err != nil {
+		return err
+	}
+
+	return after("library:timeout_after", func(db *gorm.DB) {
+		v, ok := db.InstanceGet(timeoutCancelKey)
+		if !ok {
+			return
+		}
+		t := v.(*statementTimeout)
+		if db.Error != nil && errors.Is(db.Statement.Context.Err(), context.DeadlineExceeded) {
+			db.Error = &TimeoutError{Timeout: t.timeout, SQL: db.Statement.SQL.String(), Err: db.Error}
+		}
+
+		// the rows of Row and Rows are read after the callbacks return, under the deadline,
+		// which is released once the rows are garbage collected, or when it expires
+		switch rows := db.Statement.Dest.(type) {
+		case *sql.Rows:
+			if db.Error == nil {
+				runtime.SetFinalizer(rows, func(*sql.Rows) { t.cancel() })
+				return
+			}
+		case *sql.Row:
+			if db.Error == nil {
+				runtime.SetFinalizer(rows, func(*sql.Row) { t.cancel() })
+				return
+			}
+		}
+		t.cancel()
+	})
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xingmoo/library/database/query"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const slowSQL = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c WHERE x < 100000000) SELECT count(*) FROM c"
+
+func TestWithQueryTimeout(t *testing.T) {
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithQueryTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	var n int64
+	err = db.Raw(slowSQL).Find(&n).Error
+	assert.True(t, errors.Is(err, ErrTimeout), err)
+	var te *TimeoutError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, 50*time.Millisecond, te.Timeout)
+
+	// the deadline set by the caller is kept
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = db.WithContext(ctx).Raw("SELECT 1").Find(&n).Error
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithWriteTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	err = db.Exec("CREATE TABLE t AS " + slowSQL).Error
+	assert.ErrorIs(t, err, ErrTimeout)
+
+	err = db.Exec("CREATE TABLE t (x INT)").Error
+	assert.NoError(t, err)
+}
+
+func TestWithQueryTimeout_rows(t *testing.T) {
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithQueryTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	rows, err := db.Raw("SELECT 1 UNION ALL SELECT 2").Rows()
+	assert.NoError(t, err)
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, 2, n)
+
+	// the rows are read under the deadline
+	rows, err = db.Raw(slowSQL).Rows()
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		_ = rows.Close()
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var x int
+	err = db.Raw(slowSQL).Row().Scan(&x)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithQueryTimeout_exec(t *testing.T) {
+	// an Exec of a SELECT gets the query timeout, not the write timeout
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithQueryTimeout(50*time.Millisecond), WithWriteTimeout(time.Hour))
+	assert.NoError(t, err)
+	err = db.Exec("SELECT * FROM (" + slowSQL + ")").Error
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestWithQueryTimeout_convertError(t *testing.T) {
+	db, err := OpenDialector(sqlite.Open(":memory:"), WithQueryTimeout(time.Millisecond))
+	assert.NoError(t, err)
+	// simulate a mysql error returned once the deadline expired
+	err = db.Callback().Query().After("gorm:query").Before("library:convert_error").Register("test:interrupted", func(db *gorm.DB) {
+		<-db.Statement.Context.Done()
+		db.Error = &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}
+	})
+	assert.NoError(t, err)
+
+	var n int64
+	err = db.Raw("SELECT 1").Find(&n).Error
+	var te *TimeoutError
+	assert.True(t, errors.As(err, &te), err)
+	assert.ErrorIs(t, te.Err, query.ErrLockWaitTimeout)
+}